@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"log/slog"
 	"os"
 	"os/signal"
@@ -9,27 +10,49 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jricardooliveira/redis-document-data-search/internal/api/handlers"
+	"github.com/jricardooliveira/redis-document-data-search/internal/metrics"
 	"github.com/jricardooliveira/redis-document-data-search/internal/monitor"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
 // Configuration via environment variables:
 //   REDIS_URL   - Redis connection string (default: redis://localhost:6379/0)
 //   API_PORT    - HTTP server port (default: 8080)
+//   INDEX_SCHEMA_FILE - Optional YAML/JSON file overriding the built-in index definitions
 
 func main() {
 	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
 	slog.SetDefault(logger)
 
-	app := fiber.New()
+	// Stream large request bodies (POST /import) to the handler instead of buffering them
+	app := fiber.New(fiber.Config{StreamRequestBody: true})
 	app.Use(func(c *fiber.Ctx) error {
 		monitor.CheckLimitsEveryN(200)
 		start := time.Now()
 		err := c.Next()
 		dur := time.Since(start)
 		status := c.Response().StatusCode()
+		// A returned error is turned into the response by the error handler, after this middleware
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
 		method := c.Method()
 		path := c.Path()
-		responseSize := len(c.Response().Body())
+		// Streamed responses (/export) are written after the handler returns; reading their body here would buffer it
+		streamed := c.Response().IsBodyStream()
+		responseSize := 0
+		if !streamed {
+			responseSize = len(c.Response().Body())
+		}
+		// Label metrics with the route pattern; requests no route matched only went through this middleware
+		route := c.Route().Path
+		if route == "/" && path != "/" {
+			route = ""
+		}
+		metrics.ObserveRequest(method, route, status, dur, responseSize, streamed)
 		if err != nil {
 			slog.Error("request error", "method", method, "path", path, "status", status, "duration_μs", dur.Microseconds(), "response_size_bytes", responseSize, "error", err.Error())
 		} else {
@@ -46,6 +69,8 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
+	// Load the index schema up front so a bad INDEX_SCHEMA_FILE fails at startup, not on the first search
+	schema.Get()
 	cancel := func() {}
 	defer cancel()
 
@@ -62,16 +87,31 @@ func main() {
 	// Route registrations using refactored handlers
 	app.Post("/generate_customers", handlers.GenerateCustomersHandler(redisURL))
 	app.Post("/generate_events", handlers.GenerateEventsHandler(redisURL))
+	app.Post("/generate_linked", handlers.GenerateLinkedHandler(redisURL))
+	app.Post("/generate", handlers.GenerateTemplateHandler(redisURL))
 	app.Post("/create_indexes", handlers.CreateIndexesHandler(redisURL))
+	app.Post("/rollback_indexes", handlers.RollbackIndexesHandler(redisURL))
+	app.Get("/indexes", handlers.IndexesHandler(redisURL))
 	app.Get("/search_customers", handlers.SearchCustomersHandler(redisURL))
 	app.Get("/search_events", handlers.SearchEventsHandler(redisURL))
+	app.Get("/search/explain", handlers.ExplainSearchHandler(redisURL))
+	app.Get("/search/profile", handlers.ProfileSearchHandler(redisURL))
 	app.Get("/random_event", handlers.RandomEventHandler(redisURL))
 	app.Get("/random_customer", handlers.RandomCustomerHandler(redisURL))
 	app.Get("/healthz", handlers.HealthHandler(redisURL))
+	app.Get("/metrics", handlers.MetricsHandler(redisURL))
 	app.Get("/document_by_key", handlers.DocumentByKeyHandler(redisURL))
+	app.Get("/chaos_report", handlers.ChaosReportHandler(redisURL))
+	app.Get("/export", handlers.ExportHandler(redisURL))
+	app.Post("/import", handlers.ImportHandler(redisURL))
+	app.Get("/jobs", handlers.ListJobsHandler(redisURL))
+	app.Get("/jobs/:id", handlers.GetJobHandler(redisURL))
+	app.Delete("/jobs/:id", handlers.CancelJobHandler(redisURL))
 
 	logger.Info("server starting", "port", port)
 	if err := app.Listen(":" + port); err != nil {
 		logger.Error("server error", "err", err)
 	}
+	// Stop running generation jobs; they are recorded as interrupted
+	handlers.JobManager(redisURL).Shutdown()
 }
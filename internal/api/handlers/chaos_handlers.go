@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/chaos"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/jobs"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+// chaosRun pairs the chaos profile of a generate request with the run recording it. A nil *chaosRun
+// means the request has no chaos parameter; its methods then do nothing.
+type chaosRun struct {
+	profile *faker.Chaos
+	run     *chaos.Run
+}
+
+// parseChaos reads the chaos (percentage of malformed documents) and chaos_variants parameters of
+// /generate_customers and /generate_events.
+func parseChaos(c *fiber.Ctx, generator *faker.Generator, indexName string, idField string, idOf func(j int) string) (*faker.Chaos, error) {
+	v := c.Query("chaos")
+	if v == "" {
+		return nil, nil
+	}
+	percent, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("chaos must be a percentage")
+	}
+	variants, err := faker.ParseChaosVariants(c.Query("chaos_variants"))
+	if err != nil {
+		return nil, err
+	}
+	return generator.Chaos(faker.ChaosProfile{Percent: percent, Variants: variants}, schema.Get().MustIndex(indexName), idField, idOf)
+}
+
+// newChaosRun starts recording a chaos run, or returns nil when profile is nil.
+func newChaosRun(client *redis.Client, profile *faker.Chaos, count int) (*chaosRun, error) {
+	if profile == nil {
+		return nil, nil
+	}
+	run, err := chaos.NewRun(client, profile, count)
+	if err != nil {
+		return nil, err
+	}
+	return &chaosRun{profile: profile, run: run}, nil
+}
+
+// Chaos returns the profile to break documents with (nil for none; see faker.Chaos.Break).
+func (r *chaosRun) Chaos() *faker.Chaos {
+	if r == nil {
+		return nil
+	}
+	return r.profile
+}
+
+// wrap stores the chaos run once fn has written the documents, so /chaos_report can find it.
+func (r *chaosRun) wrap(client *redis.Client, fn jobs.RunFunc) jobs.RunFunc {
+	if r == nil {
+		return fn
+	}
+	return func(ctx context.Context, report func(written, failed int64)) (redisutil.BulkStats, error) {
+		stats, err := fn(ctx, report)
+		if ferr := r.run.Finish(context.Background(), client, r.profile); err == nil {
+			err = ferr
+		}
+		return stats, err
+	}
+}
+
+// ChaosReportHandler reports how the documents of the latest chaos run of type (customers or events) were
+// indexed and how they behave in search. It waits for any background indexing to finish first.
+func ChaosReportHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		var name string
+		switch c.Query("type") {
+		case "customers":
+			name = schema.CustomerIndex
+		case "events":
+			name = schema.EventIndex
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "type must be customers or events"})
+		}
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		idx := schema.Get().MustIndex(name)
+		run, err := chaos.LoadRun(c.Context(), client, idx.Prefix)
+		if errors.Is(err, chaos.ErrNoRun) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		report, err := chaos.BuildReport(c.Context(), client, idx, run)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{"run": run.StartedAt, "report": report, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+}
+
+// respond writes the result of a wait=true generation, adding the chaos report when there is one.
+func (r *chaosRun) respond(c *fiber.Ctx, client *redis.Client, stats redisutil.BulkStats, err error, start time.Time) error {
+	if r == nil || err != nil || stats.Failed > 0 {
+		return bulkResponse(c, stats, err, time.Since(start).Milliseconds())
+	}
+	report, err := chaos.BuildReport(c.Context(), client, r.profile.Index(), *r.run)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "stored": stats.Written, "stats": stats, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+	return PrettyJSON(c, fiber.Map{"status": "ok", "stored": stats.Written, "stats": stats, "chaos_report": report, "query_time_ms": time.Since(start).Milliseconds()})
+}
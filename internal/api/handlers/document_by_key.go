@@ -3,13 +3,16 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
 )
 
-// DocumentByKeyHandler returns the raw JSON document for a given Redis key (customer:event:...)
+// DocumentByKeyHandler returns the raw JSON document for a given Redis key (customer:event:...),
+// or only the subtrees listed in fields= (JSON.GET key path...)
 func DocumentByKeyHandler(redisURL string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
@@ -21,6 +24,33 @@ func DocumentByKeyHandler(redisURL string) fiber.Handler {
 			})
 		}
 		client := redisutil.GetSingletonRedisClient(redisURL)
+		if fieldsParam := c.Query("fields"); fieldsParam != "" {
+			fields, err := redisutil.ParseFieldPaths(fieldsParam)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":         err.Error(),
+					"query_time_ms": time.Since(start).Milliseconds(),
+				})
+			}
+			doc, err := redisutil.GetJSONFields(client, key, fields)
+			if errors.Is(err, redis.Nil) {
+				return c.Status(404).JSON(fiber.Map{
+					"error":         "not found",
+					"query_time_ms": time.Since(start).Milliseconds(),
+				})
+			}
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{
+					"error":         err.Error(),
+					"query_time_ms": time.Since(start).Milliseconds(),
+				})
+			}
+			return c.JSON(fiber.Map{
+				"key":           key,
+				"document":      doc,
+				"query_time_ms": time.Since(start).Milliseconds(),
+			})
+		}
 		ctx := context.Background()
 		jsonStr, err := client.Do(ctx, "JSON.GET", key, "$").Text()
 		if err != nil {
@@ -6,81 +6,83 @@ import (
 	"math/rand"
 	"strconv"
 	"time"
-	"runtime"
-	"sync"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
+// GenerateEventsHandler starts a background job that generates count random documents with the pipelined
+// bulk writer (batch_size documents per round trip, workers concurrent pipelines, mset=true for JSON.MSET)
+// and answers 202 with the job ID to poll at /jobs/{id}. Pass wait=true to block until the write is done.
+// keys selects how documents are keyed (see redisutil.KeyStrategy); the key range is reported in the stats.
+// seed makes the data reproducible: the same seed and count produce identical documents. chaos writes that
+// percentage of documents as malformed variants (chaos_variants, default all); see /chaos_report.
 func GenerateEventsHandler(redisURL string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		count, _ := strconv.Atoi(c.Query("count", "1000"))
+		count, err := strconv.Atoi(c.Query("count", "1000"))
+		if err != nil || count < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "count must be a non-negative integer"})
+		}
+		opts, err := parseBulkOptions(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		strategy, err := parseKeyStrategy(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		generator, err := parseGenerator(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		profile, err := parseChaos(c, generator, schema.EventIndex, "event_id", func(j int) string {
+			return generator.Event(j).EventID
+		})
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
 		start := time.Now()
 		client := redisutil.GetSingletonRedisClient(redisURL)
-
-		concurrency := runtime.NumCPU() / 2
-		if concurrency < 1 {
-			concurrency = 1
+		keys, err := redisutil.NewKeyAllocator(c.Context(), client, strategy, "event:", count)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
 		}
-		if count < concurrency {
-			concurrency = count
+		chaos, err := newChaosRun(client, profile, count)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
 		}
-		var wg sync.WaitGroup
-		sem := make(chan struct{}, concurrency)
-		errCh := make(chan error, count)
-
-		for i := 0; i < count; i++ {
-			wg.Add(1)
-			sem <- struct{}{}
-			go func(i int) {
-				defer wg.Done()
-				event := faker.RandomEvent()
-				key := "event:" + strconv.Itoa(i)
-				if err := redisutil.StoreJSON(client, key, event); err != nil {
-					errCh <- err
-				}
-				<-sem
-			}(i)
+		gen := func(i int) (string, interface{}, error) {
+			doc := generator.Event(i)
+			key, err := keys.Key(i, doc.EventID)
+			if err != nil {
+				return key, nil, err
+			}
+			out, err := chaos.Chaos().Break(i, key, doc)
+			return key, out, err
 		}
-		wg.Wait()
-		close(errCh)
-
-		if len(errCh) > 0 {
-			queryTimeMs := time.Since(start).Milliseconds()
-			return c.Status(500).JSON(fiber.Map{"error": (<-errCh).Error(), "query_time_ms": queryTimeMs})
+		if !c.QueryBool("wait", false) {
+			return startGenerateJob(c, redisURL, "generate_events", count, opts, keys, generator, chaos, gen)
 		}
-		queryTimeMs := time.Since(start).Milliseconds()
-		return PrettyJSON(c, fiber.Map{"status": "ok", "stored": count, "query_time_ms": queryTimeMs})
+		stats, err := chaos.wrap(client, generateRun(client, count, opts, keys, gen))(c.Context(), nil)
+		return chaos.respond(c, client, stats, err, start)
 	}
 }
 
+// eventSearchParams are the /search_events query parameters that are not field filters
+var eventSearchParams = map[string]bool{"q": true, "limit": true, "offset": true, "cursor": true, "from": true, "to": true, "sort_by": true, "sort_dir": true, "fields": true}
+
+// SearchEventsHandler searches events by identifiers, optionally within a from/to time range
+// (RFC3339, Unix seconds or e.g. -24h) and sorted with sort_by/sort_dir.
 func SearchEventsHandler(redisURL string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		client := redisutil.GetSingletonRedisClient(redisURL)
-		identifiers := map[string]string{}
-		for k, v := range c.Queries() {
-			if k != "limit" && k != "offset" {
-				identifiers[k] = v
-			}
-		}
-		limit, err1 := strconv.Atoi(c.Query("limit", "10"))
-		if err1 != nil || limit < 1 {
-			return c.Status(400).JSON(fiber.Map{"error": "limit must be a positive integer"})
-		}
-		offset, err2 := strconv.Atoi(c.Query("offset", "0"))
-		if err2 != nil || offset < 0 {
-			return c.Status(400).JSON(fiber.Map{"error": "offset must be a non-negative integer"})
-		}
-		query := BuildRediSearchQuery(identifiers)
-		start := time.Now()
-		results, err := redisutil.SearchFTSWithLimit(client, "eventIdx", query, limit, offset)
-		queryTimeMs := time.Since(start).Milliseconds()
+		idx := schema.Get().MustIndex(schema.EventIndex)
+		searchQuery, opts, err := parseSearchRequest(c, idx, eventSearchParams)
 		if err != nil {
-			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": queryTimeMs})
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
 		}
-		return PrettyJSON(c, fiber.Map{"results": results, "query_time_ms": queryTimeMs})
+		return pagedSearch(c, client, idx.Name, searchQuery, opts)
 	}
 }
 
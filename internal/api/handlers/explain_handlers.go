@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// searchTarget resolves the type= parameter of /search/explain and /search/profile to the index and the
+// non-filter parameters of the search endpoint it mirrors (customers -> /search_customers, events -> /search_events).
+func searchTarget(c *fiber.Ctx) (*schema.Index, map[string]bool, error) {
+	var name string
+	var params map[string]bool
+	switch c.Query("type") {
+	case "customers":
+		name, params = schema.CustomerIndex, customerSearchParams
+	case "events":
+		name, params = schema.EventIndex, eventSearchParams
+	default:
+		return nil, nil, fmt.Errorf("type must be customers or events")
+	}
+	withType := map[string]bool{"type": true}
+	for k := range params {
+		withType[k] = true
+	}
+	return schema.Get().MustIndex(name), withType, nil
+}
+
+// ExplainSearchHandler runs FT.EXPLAIN on the query a search request would generate and returns the
+// parsed execution plan.
+func ExplainSearchHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		idx, params, err := searchTarget(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		searchQuery, _, err := parseSearchRequest(c, idx, params)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		plan, raw, err := redisutil.ExplainSearch(client, idx.Name, searchQuery)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query": searchQuery, "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{
+			"index":         idx.Name,
+			"query":         searchQuery,
+			"plan":          plan,
+			"explain":       raw,
+			"query_time_ms": time.Since(start).Milliseconds(),
+		})
+	}
+}
+
+// ProfileSearchHandler runs the search a request would perform under FT.PROFILE and returns the
+// iterator tree, counters and timings as JSON.
+func ProfileSearchHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		idx, params, err := searchTarget(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		searchQuery, opts, err := parseSearchRequest(c, idx, params)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		profile, err := redisutil.ProfileSearch(client, idx.Name, searchQuery, opts)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query": searchQuery, "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{
+			"index":         idx.Name,
+			"query":         searchQuery,
+			"total":         profile.Total,
+			"profile":       profile.Profile,
+			"query_time_ms": time.Since(start).Milliseconds(),
+		})
+	}
+}
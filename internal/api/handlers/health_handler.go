@@ -40,7 +40,7 @@ func HealthHandler(redisURL string) fiber.Handler {
 			memInfo["used_memory_human"] = usedHuman
 		}
 
-		indexes, idxErr := redisutil.GetIndexesAndFields()
+		indexes, idxErr := redisutil.GetIndexesAndFields(client)
 		queryTimeMs := time.Since(start).Milliseconds()
 		resp := fiber.Map{
 			"status":         "ok",
@@ -1,21 +1,112 @@
 package handlers
 
 import (
+	"context"
+	"log/slog"
 	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
+// CreateIndexesHandler builds a new version of every index in the schema. By default it returns 202 as soon
+// as the new versions are created and swaps the aliases in the background once indexing completes, so
+// searches keep working against the previous versions. Pass wait=true to block until the swap is done.
 func CreateIndexesHandler(redisURL string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		start := time.Now()
 		client := redisutil.GetSingletonRedisClient(redisURL)
-		err1 := redisutil.CreateCustomerIndex(client)
-		err2 := redisutil.CreateEventIndex(client)
+		wait := c.QueryBool("wait", false)
+
+		versions := fiber.Map{}
+		errs := fiber.Map{}
+		done := make(chan struct{}, len(schema.Get().Indexes))
+		pending := 0
+		for _, idx := range schema.Get().Indexes {
+			physical, err := redisutil.StartReindex(client, &idx)
+			if err != nil {
+				errs[idx.Name] = err.Error()
+				continue
+			}
+			versions[idx.Name] = physical
+			pending++
+			go func(alias, physical string) {
+				defer func() { done <- struct{}{} }()
+				err := redisutil.FinishReindex(context.Background(), client, alias, physical, nil)
+				if err != nil {
+					slog.Error("reindex failed", "index", alias, "version", physical, "error", err)
+					return
+				}
+				slog.Info("reindex complete, alias swapped", "index", alias, "version", physical)
+			}(idx.Name, physical)
+		}
+		if wait {
+			for i := 0; i < pending; i++ {
+				<-done
+			}
+		}
 		queryTimeMs := time.Since(start).Milliseconds()
-		if err1 != nil || err2 != nil {
-			return c.JSON(fiber.Map{"customerIdx": err1, "eventIdx": err2, "query_time_ms": queryTimeMs})
+		if len(errs) > 0 {
+			return c.Status(500).JSON(fiber.Map{"errors": errs, "versions": versions, "query_time_ms": queryTimeMs})
+		}
+		if wait {
+			return c.JSON(fiber.Map{"status": "ok", "versions": versions, "query_time_ms": queryTimeMs})
+		}
+		return c.Status(202).JSON(fiber.Map{"status": "indexing", "versions": versions, "query_time_ms": queryTimeMs})
+	}
+}
+
+// RollbackIndexesHandler points each index alias (or only ?index=name) back at its previous version.
+func RollbackIndexesHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		names := []string{c.Query("index")}
+		if names[0] == "" {
+			names = names[:0]
+			for _, idx := range schema.Get().Indexes {
+				names = append(names, idx.Name)
+			}
+		} else if _, ok := schema.Get().Index(names[0]); !ok {
+			return c.Status(400).JSON(fiber.Map{"error": "unknown index: " + names[0]})
+		}
+		versions := fiber.Map{}
+		errs := fiber.Map{}
+		for _, name := range names {
+			target, err := redisutil.RollbackIndex(client, name)
+			if err != nil {
+				errs[name] = err.Error()
+				continue
+			}
+			versions[name] = target
+		}
+		queryTimeMs := time.Since(start).Milliseconds()
+		if len(errs) > 0 {
+			return c.Status(500).JSON(fiber.Map{"errors": errs, "versions": versions, "query_time_ms": queryTimeMs})
+		}
+		return c.JSON(fiber.Map{"status": "ok", "versions": versions, "query_time_ms": queryTimeMs})
+	}
+}
+
+// IndexesHandler lists every physical index on the server with its live FT.INFO data,
+// plus the physical index each schema alias currently points at.
+func IndexesHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		indexes, err := redisutil.ListIndexInfo(client)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		aliases := fiber.Map{}
+		for _, idx := range schema.Get().Indexes {
+			current, err := redisutil.CurrentIndex(client, idx.Name)
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+			}
+			aliases[idx.Name] = current
 		}
-		return c.JSON(fiber.Map{"status": "ok", "query_time_ms": queryTimeMs})
+		return PrettyJSON(c, fiber.Map{"indexes": indexes, "aliases": aliases, "query_time_ms": time.Since(start).Milliseconds()})
 	}
 }
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/jobs"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// JobManager returns the process-wide job manager. The API server calls Shutdown on it when stopping.
+func JobManager(redisURL string) *jobs.Manager {
+	return jobs.GetSingletonManager(redisutil.GetSingletonRedisClient(redisURL))
+}
+
+// startJob runs fn as a background job and answers 202 with the job ID.
+func startJob(c *fiber.Ctx, redisURL string, jobType string, params map[string]interface{}, total int64, fn jobs.RunFunc) error {
+	start := time.Now()
+	job, err := JobManager(redisURL).Start(jobType, params, total, fn)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+	}
+	return c.Status(202).JSON(fiber.Map{
+		"job_id":        job.ID,
+		"status":        job.Status,
+		"status_url":    "/jobs/" + job.ID,
+		"query_time_ms": time.Since(start).Milliseconds(),
+	})
+}
+
+// generateParams records the options of a generation job; the seed and event mix are included when given.
+func generateParams(opts redisutil.BulkOptions, keys redisutil.KeyStrategy, generator *faker.Generator) map[string]interface{} {
+	params := map[string]interface{}{"batch_size": opts.BatchSize, "workers": opts.Workers, "max_retries": *opts.MaxRetries, "mset": opts.UseMSET, "keys": keys}
+	if generator.Reproducible() {
+		params["seed"] = generator.Seed()
+	}
+	if generator.Events() != faker.DefaultEventCatalogue() {
+		params["event_mix"] = generator.Events().Share()
+	}
+	return params
+}
+
+// startGenerateJob runs a bulk generation as a background job. The key range produced is reported in the
+// job's stats; a chaos run, if any, is stored when the job ends.
+func startGenerateJob(c *fiber.Ctx, redisURL string, jobType string, count int, opts redisutil.BulkOptions, keys *redisutil.KeyAllocator, generator *faker.Generator, chaos *chaosRun, gen func(i int) (string, interface{}, error)) error {
+	params := generateParams(opts, keys.Range().Strategy, generator)
+	params["count"] = count
+	if chaos != nil {
+		params["chaos"] = chaos.profile.Profile()
+	}
+	client := redisutil.GetSingletonRedisClient(redisURL)
+	return startJob(c, redisURL, jobType, params, int64(count), chaos.wrap(client, generateRun(client, count, opts, keys, gen)))
+}
+
+// generateRun returns the job body of a bulk generation.
+func generateRun(client *redis.Client, count int, opts redisutil.BulkOptions, keys *redisutil.KeyAllocator, gen func(i int) (string, interface{}, error)) jobs.RunFunc {
+	return func(ctx context.Context, report func(written, failed int64)) (redisutil.BulkStats, error) {
+		opts.OnProgress = report
+		stats, err := redisutil.BulkGenerate(ctx, client, count, opts, gen)
+		stats.Keys = append(stats.Keys, keys.Range())
+		return stats, err
+	}
+}
+
+// GetJobHandler reports the progress, rate and errors of a job.
+func GetJobHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		job, err := JobManager(redisURL).Get(c.Context(), c.Params("id"))
+		if errors.Is(err, jobs.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{"job": job, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+}
+
+// ListJobsHandler lists the most recent jobs (limit, default 20), newest first.
+func ListJobsHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		limit, err := strconv.Atoi(c.Query("limit", "20"))
+		if err != nil || limit < 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "limit must be a positive integer"})
+		}
+		list, err := JobManager(redisURL).List(c.Context(), limit)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{"jobs": list, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+}
+
+// CancelJobHandler requests cancellation of a running job. Documents already written are kept.
+func CancelJobHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		job, err := JobManager(redisURL).Cancel(c.Context(), c.Params("id"))
+		if errors.Is(err, jobs.ErrNotFound) {
+			return c.Status(404).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		if job.Finished() {
+			return c.Status(409).JSON(fiber.Map{"error": "job already " + string(job.Status), "job": job, "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return c.Status(202).JSON(fiber.Map{"status": "cancelling", "job": job, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// GenerateLinkedHandler generates a population of customers and then events that belong to them: each
+// event carries the visitor/session IDs, email, phone and call/chat IDs of one of the customers. The
+// distribution parameter (uniform, zipf or powerlaw, with exponent) controls how many events each customer
+// gets. It takes the same batch_size, workers, mset, keys, seed and wait parameters as /generate_customers.
+func GenerateLinkedHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		customers, err := strconv.Atoi(c.Query("customers", "1000"))
+		if err != nil || customers < 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "customers must be a positive integer"})
+		}
+		events, err := strconv.Atoi(c.Query("events", "10000"))
+		if err != nil || events < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "events must be a non-negative integer"})
+		}
+		dist, err := faker.ParseDistribution(c.Query("distribution"))
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		exponent := 0.0
+		if v := c.Query("exponent"); v != "" {
+			if exponent, err = strconv.ParseFloat(v, 64); err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "exponent must be a number"})
+			}
+		}
+		opts, err := parseBulkOptions(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		strategy, err := parseKeyStrategy(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		generator, err := parseGenerator(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		population, err := generator.Population(customers, dist, exponent)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		customerKeys, err := redisutil.NewKeyAllocator(c.Context(), client, strategy, "customer:", customers)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		eventKeys, err := redisutil.NewKeyAllocator(c.Context(), client, strategy, "event:", events)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		run := func(ctx context.Context, report func(written, failed int64)) (redisutil.BulkStats, error) {
+			return generateLinked(ctx, client, population, events, opts, customerKeys, eventKeys, report)
+		}
+		if !c.QueryBool("wait", false) {
+			params := generateParams(opts, strategy, generator)
+			params["customers"] = customers
+			params["events"] = events
+			params["distribution"] = dist
+			params["exponent"] = population.Exponent()
+			return startJob(c, redisURL, "generate_linked", params, int64(customers+events), run)
+		}
+		stats, err := run(c.Context(), nil)
+		return bulkResponse(c, stats, err, time.Since(start).Milliseconds())
+	}
+}
+
+// generateLinked writes the customers of population and then events linked to them. report, if not nil,
+// receives running totals over both datasets.
+func generateLinked(ctx context.Context, client *redis.Client, population *faker.Population, events int, opts redisutil.BulkOptions, customerKeys, eventKeys *redisutil.KeyAllocator, report func(written, failed int64)) (redisutil.BulkStats, error) {
+	opts.OnProgress = report
+	stats, err := redisutil.BulkGenerate(ctx, client, population.Size(), opts, func(i int) (string, interface{}, error) {
+		doc := population.Customer(i)
+		key, err := customerKeys.Key(i, doc.CustomerID)
+		return key, doc, err
+	})
+	stats.Keys = append(stats.Keys, customerKeys.Range())
+	if err != nil {
+		return stats, err
+	}
+	if report != nil {
+		opts.OnProgress = func(written, failed int64) {
+			report(stats.Written+written, stats.Failed+failed)
+		}
+	}
+	eventStats, err := redisutil.BulkGenerate(ctx, client, events, opts, func(i int) (string, interface{}, error) {
+		doc := population.Event(i)
+		key, err := eventKeys.Key(i, doc.EventID)
+		return key, doc, err
+	})
+	eventStats.Keys = append(eventStats.Keys, eventKeys.Range())
+	stats.Add(eventStats)
+	return stats, err
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/jricardooliveira/redis-document-data-search/internal/metrics"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsHandler serves the API metrics in the Prometheus text format, including the connection pool of
+// the Redis client the other handlers share.
+func MetricsHandler(redisURL string) fiber.Handler {
+	metrics.RegisterRedisPool(redisutil.GetSingletonRedisClient(redisURL))
+	return adaptor.HTTPHandler(promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// ExportHandler streams documents as NDJSON, one {"key", "doc"} object per line. The documents are those
+// of type (customers or events) or with the key prefix prefix; q narrows a type to the matches of a
+// boolean query. With checkpoints=true a {"checkpoint": {...}} line follows every batch; passing its
+// cursor back as cursor= resumes after that batch. A failure after the response has started is reported
+// as a final {"error", "checkpoint"} line. gzip=true compresses the stream (Content-Encoding: gzip).
+func ExportHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		opts := redisutil.NDJSONExportOptions{Prefix: c.Query("prefix")}
+		var idx *schema.Index
+		switch c.Query("type") {
+		case "customers":
+			idx = schema.Get().MustIndex(schema.CustomerIndex)
+		case "events":
+			idx = schema.Get().MustIndex(schema.EventIndex)
+		case "":
+		default:
+			return c.Status(400).JSON(fiber.Map{"error": "type must be customers or events"})
+		}
+		if idx != nil && opts.Prefix == "" {
+			opts.Prefix = idx.Prefix
+		}
+		if q := c.Query("q"); q != "" {
+			if idx == nil {
+				return c.Status(400).JSON(fiber.Map{"error": "q needs a type (customers or events)"})
+			}
+			compiled, err := query.BuildWithQuery(idx, nil, q)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+			}
+			opts.Index, opts.Query = idx.Name, compiled
+		}
+		if opts.Prefix == "" && opts.Index == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "type or prefix is required"})
+		}
+		if v := c.Query("batch_size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > redisutil.MaxBulkBatchSize {
+				return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("batch_size must be between 1 and %d", redisutil.MaxBulkBatchSize)})
+			}
+			opts.BatchSize = n
+		}
+		if v := c.Query("cursor"); v != "" {
+			cursor, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "cursor must be a checkpoint cursor"})
+			}
+			opts.Resume.Cursor = cursor
+		}
+		compress := c.QueryBool("gzip", false)
+		checkpoints := c.QueryBool("checkpoints", false)
+		client := redisutil.GetSingletonRedisClient(redisURL)
+
+		c.Set(fiber.HeaderContentType, "application/x-ndjson")
+		if compress {
+			c.Set(fiber.HeaderContentEncoding, "gzip")
+		}
+		// The stream is written after the handler returns, so it must not use c, nor strings read from the
+		// request, whose buffer is reused by then.
+		opts.Prefix, opts.Query = strings.Clone(opts.Prefix), strings.Clone(opts.Query)
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			var out io.Writer = w
+			var gz *gzip.Writer
+			if compress {
+				gz = gzip.NewWriter(w)
+				out = gz
+			}
+			writeLine := func(v interface{}) {
+				line, _ := json.Marshal(v)
+				_, _ = out.Write(append(line, '\n'))
+			}
+			opts.OnBatch = func(cp redisutil.ExportCheckpoint) error {
+				if checkpoints {
+					writeLine(fiber.Map{"checkpoint": cp})
+				}
+				if gz != nil {
+					if err := gz.Flush(); err != nil {
+						return err
+					}
+				}
+				// Fails once the client has gone away, which stops the export.
+				return w.Flush()
+			}
+			cp, err := redisutil.ExportNDJSON(context.Background(), client, out, opts)
+			if err != nil {
+				slog.Error("export failed", "error", err, "cursor", cp.Cursor, "exported", cp.Exported)
+				writeLine(fiber.Map{"error": err.Error(), "checkpoint": cp})
+			}
+			if gz != nil {
+				_ = gz.Close()
+			}
+			_ = w.Flush()
+		})
+		return nil
+	}
+}
+
+// ImportHandler writes the documents of an NDJSON request body (as produced by /export, plain or gzip)
+// with pipelined batches. skip passes over the first lines of the body, such as the lines an interrupted
+// import reported done; the response reports lines, the number of lines done.
+func ImportHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		bulk, err := parseBulkOptions(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		opts := redisutil.NDJSONImportOptions{BulkOptions: bulk}
+		if v := c.Query("skip"); v != "" {
+			if opts.Skip, err = strconv.ParseInt(v, 10, 64); err != nil || opts.Skip < 0 {
+				return c.Status(400).JSON(fiber.Map{"error": "skip must be a non-negative integer"})
+			}
+		}
+		body := c.Context().RequestBodyStream()
+		if body == nil {
+			body = bytes.NewReader(c.Body())
+		}
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		stats, lines, err := redisutil.ImportNDJSON(c.Context(), client, body, opts)
+		if err == nil && stats.Failed > 0 && len(stats.Errors) > 0 {
+			err = fmt.Errorf("%s", stats.Errors[0].Error)
+		}
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "stored": stats.Written, "lines": lines, "stats": stats, "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		return PrettyJSON(c, fiber.Map{"status": "ok", "stored": stats.Written, "lines": lines, "stats": stats, "query_time_ms": time.Since(start).Milliseconds()})
+	}
+}
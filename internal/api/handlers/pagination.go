@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// pageCursor is the decoded form of the opaque next_cursor returned by the search endpoints.
+// A cursor without ID comes from the first page; following it opens an FT.AGGREGATE cursor
+// (ID) that every later page is read from, so deep pages cost the same as the first ones.
+// Query is a hash of the search the cursor belongs to (see searchHash).
+type pageCursor struct {
+	Index  string `json:"i"`
+	Query  string `json:"q"`
+	Offset int    `json:"o"`
+	Total  int64  `json:"t"`
+	ID     int64  `json:"c,omitempty"`
+}
+
+// searchHash identifies a search by its compiled query, sort order and fields, so that a cursor is not
+// followed with different parameters, which would return pages of another search under a stale total.
+func searchHash(query string, opts redisutil.SearchOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%q %q %t %q", query, opts.SortBy, opts.SortDesc, opts.Fields)
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:12])
+}
+
+func encodeCursor(pc pageCursor) string {
+	data, _ := json.Marshal(pc)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	var pc pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pc, err
+	}
+	err = json.Unmarshal(data, &pc)
+	return pc, err
+}
+
+// pagedSearch runs one page of a search and writes the response with total, limit, offset and
+// next_cursor. Without a cursor parameter the page comes from AggregatePage; with one, the same filters,
+// sort and fields must be sent again (400 otherwise) and the page is read from the aggregate cursor,
+// which continues the first page in the same order.
+func pagedSearch(c *fiber.Ctx, client *redis.Client, index string, query string, opts redisutil.SearchOptions) error {
+	start := time.Now()
+	cursorParam := c.Query("cursor")
+	offset := opts.Offset
+	hash := searchHash(query, opts)
+	var page redisutil.SearchResult
+	var err error
+	if cursorParam == "" {
+		page, err = redisutil.AggregatePage(c.Context(), client, index, query, opts)
+	} else {
+		pc, decodeErr := decodeCursor(cursorParam)
+		if decodeErr != nil || pc.Index != index {
+			return c.Status(400).JSON(fiber.Map{"error": "invalid cursor"})
+		}
+		if pc.Query != hash {
+			return c.Status(400).JSON(fiber.Map{"error": "cursor belongs to a different search; send the same filters, q, sort and fields"})
+		}
+		offset = pc.Offset
+		if pc.ID == 0 {
+			opts.Offset = pc.Offset
+			page, err = redisutil.AggregateWithCursor(client, index, query, opts, pc.Total)
+		} else {
+			page, err = redisutil.ReadCursor(client, index, pc.ID, opts.Limit, opts.Fields)
+		}
+		page.Total = pc.Total
+		if err != nil && strings.Contains(strings.ToLower(err.Error()), "cursor not found") {
+			return c.Status(410).JSON(fiber.Map{"error": "cursor expired, restart the search", "query_time_ms": time.Since(start).Milliseconds()})
+		}
+	}
+	queryTimeMs := time.Since(start).Milliseconds()
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": queryTimeMs})
+	}
+
+	var next interface{}
+	consumed := offset + len(page.Docs)
+	switch {
+	case int64(consumed) >= page.Total || len(page.Docs) == 0:
+		if page.CursorID != 0 {
+			_ = redisutil.DeleteCursor(client, index, page.CursorID)
+		}
+	case cursorParam == "":
+		next = encodeCursor(pageCursor{Index: index, Query: hash, Offset: consumed, Total: page.Total})
+	case page.CursorID != 0:
+		next = encodeCursor(pageCursor{Index: index, Query: hash, Offset: consumed, Total: page.Total, ID: page.CursorID})
+	}
+	return PrettyJSON(c, fiber.Map{
+		"results":       page.Docs,
+		"total":         page.Total,
+		"limit":         opts.Limit,
+		"offset":        offset,
+		"next_cursor":   next,
+		"query_time_ms": queryTimeMs,
+	})
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// parseSearchRequest reads the parameters shared by the search endpoints (and /search/explain and
+// /search/profile, which mirror them): every query parameter not in params is a field filter, plus q=,
+// limit, offset, fields and, where params lists them, from/to and sort_by/sort_dir.
+func parseSearchRequest(c *fiber.Ctx, idx *schema.Index, params map[string]bool) (string, redisutil.SearchOptions, error) {
+	var opts redisutil.SearchOptions
+	identifiers := map[string]string{}
+	for k, v := range c.Queries() {
+		if !params[k] {
+			identifiers[k] = v
+		}
+	}
+	limit, err := strconv.Atoi(c.Query("limit", "10"))
+	if err != nil || limit < 1 {
+		return "", opts, fmt.Errorf("limit must be a positive integer")
+	}
+	offset, err := strconv.Atoi(c.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		return "", opts, fmt.Errorf("offset must be a non-negative integer")
+	}
+	opts.Limit, opts.Offset = limit, offset
+	searchQuery, err := query.BuildWithQuery(idx, identifiers, c.Query("q"))
+	if err != nil {
+		return "", opts, err
+	}
+	if params["from"] {
+		if from, to := c.Query("from"), c.Query("to"); from != "" || to != "" {
+			min, max, err := schema.TimeRangeBounds(from, to, time.Now())
+			if err != nil {
+				return "", opts, err
+			}
+			searchQuery = query.Intersect(searchQuery, query.NumericRange(schema.EventTimeField, min, max))
+		}
+	}
+	if params["sort_by"] {
+		opts.SortBy = c.Query("sort_by")
+		opts.SortDesc, err = idx.ValidateSort(opts.SortBy, c.Query("sort_dir"))
+		if err != nil {
+			return "", opts, err
+		}
+	}
+	opts.Fields, err = redisutil.ParseFieldPaths(c.Query("fields"))
+	if err != nil {
+		return "", opts, err
+	}
+	return searchQuery, opts, nil
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+)
+
+// GenerateTemplateHandler generates count documents described by the YAML or JSON template in the request
+// body, under the template's prefix or the prefix parameter. It takes the same batch_size, workers, mset,
+// keys, seed and wait parameters as /generate_customers.
+func GenerateTemplateHandler(redisURL string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tmpl, err := faker.ParseTemplate(c.Body())
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		// Copied: the job writes keys under prefix after the request buffer has been reused.
+		prefix := strings.Clone(c.Query("prefix", tmpl.Prefix))
+		if prefix == "" {
+			return c.Status(400).JSON(fiber.Map{"error": "the template has no prefix or name; set prefix"})
+		}
+		count, err := strconv.Atoi(c.Query("count", "1000"))
+		if err != nil || count < 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "count must be a non-negative integer"})
+		}
+		opts, err := parseBulkOptions(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		strategy, err := parseKeyStrategy(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		generator, err := parseGenerator(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		start := time.Now()
+		client := redisutil.GetSingletonRedisClient(redisURL)
+		keys, err := redisutil.NewKeyAllocator(c.Context(), client, strategy, prefix, count)
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{"error": err.Error(), "query_time_ms": time.Since(start).Milliseconds()})
+		}
+		gen := func(i int) (string, interface{}, error) {
+			doc, err := generator.Document(tmpl, i)
+			if err != nil {
+				return "", nil, err
+			}
+			key, err := keys.Key(i, tmpl.ID(doc))
+			return key, doc, err
+		}
+		run := generateRun(client, count, opts, keys, gen)
+		if !c.QueryBool("wait", false) {
+			params := generateParams(opts, strategy, generator)
+			params["count"] = count
+			params["template"] = tmpl.Name
+			params["prefix"] = prefix
+			return startJob(c, redisURL, "generate_template", params, int64(count), run)
+		}
+		stats, err := run(c.Context(), nil)
+		return bulkResponse(c, stats, err, time.Since(start).Milliseconds())
+	}
+}
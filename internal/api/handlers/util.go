@@ -3,9 +3,11 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
 )
 
 // PrettyJSON returns indented JSON as HTML <pre> for debugging (optional, can be replaced with normal JSON)
@@ -14,26 +16,62 @@ func PrettyJSON(c *fiber.Ctx, v interface{}) error {
 	return c.Type("json").Send(pretty)
 }
 
-func escapeRediSearchValue(val string) string {
-	// Escape double quotes
-	val = strings.ReplaceAll(val, `"`, `\"`)
-	// Escape other RediSearch special characters
-	specialChars := []string{"-", "[", "]", "{", "}", "(", ")", "<", ">", ":", "~", "*", "?", "|", "&", "'", "!", "@", "#", "$", "%", "^", "="}
-	for _, ch := range specialChars {
-		val = strings.ReplaceAll(val, ch, `\`+ch)
+// parseBulkOptions reads the batch_size, workers, max_retries and mset parameters of the generate endpoints
+func parseBulkOptions(c *fiber.Ctx) (redisutil.BulkOptions, error) {
+	var opts redisutil.BulkOptions
+	var err error
+	if v := c.Query("batch_size"); v != "" {
+		if opts.BatchSize, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf("batch_size must be an integer")
+		}
 	}
-	return val
+	if v := c.Query("workers"); v != "" {
+		if opts.Workers, err = strconv.Atoi(v); err != nil {
+			return opts, fmt.Errorf("workers must be an integer")
+		}
+	}
+	if v := c.Query("max_retries"); v != "" {
+		retries, err := strconv.Atoi(v)
+		if err != nil {
+			return opts, fmt.Errorf("max_retries must be an integer")
+		}
+		opts.MaxRetries = &retries
+	}
+	opts.UseMSET = c.QueryBool("mset", false)
+	return opts, opts.Validate()
+}
+
+// parseGenerator reads the seed and event_mix parameters of the generate endpoints. Without a seed
+// documents are random; with it the same seed and count produce identical documents. event_mix
+// ("page_view=70,purchase=30") replaces the default event type weights.
+func parseGenerator(c *fiber.Ctx) (*faker.Generator, error) {
+	generator := faker.NewRandomGenerator()
+	if v := c.Query("seed"); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("seed must be an integer")
+		}
+		generator = faker.NewGenerator(seed)
+	}
+	events, err := faker.DefaultEventCatalogue().Mix(c.Query("event_mix"))
+	if err != nil {
+		return nil, err
+	}
+	return generator.WithEvents(events), nil
+}
+
+// parseKeyStrategy reads the keys parameter of the generate endpoints (default sequential)
+func parseKeyStrategy(c *fiber.Ctx) (redisutil.KeyStrategy, error) {
+	return redisutil.ParseKeyStrategy(c.Query("keys"))
 }
 
-// BuildRediSearchQuery constructs a RediSearch query string from a map of identifiers
-func BuildRediSearchQuery(identifiers map[string]string) string {
-	var parts []string
-	for k, v := range identifiers {
-		escaped := escapeRediSearchValue(v)
-		parts = append(parts, fmt.Sprintf("@%s:\"%s\"", k, escaped))
+// bulkResponse writes the result of a bulk write: 200 with the stats, or 500 with the first batch error
+func bulkResponse(c *fiber.Ctx, stats redisutil.BulkStats, err error, queryTimeMs int64) error {
+	if err == nil && stats.Failed > 0 && len(stats.Errors) > 0 {
+		err = fmt.Errorf("%s", stats.Errors[0].Error)
 	}
-	if len(parts) == 0 {
-		return "*"
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": err.Error(), "stored": stats.Written, "stats": stats, "query_time_ms": queryTimeMs})
 	}
-	return strings.Join(parts, " ")
+	return PrettyJSON(c, fiber.Map{"status": "ok", "stored": stats.Written, "stats": stats, "query_time_ms": queryTimeMs})
 }
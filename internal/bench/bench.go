@@ -0,0 +1,240 @@
+// Package bench load-tests the search paths with the samples of sample_to_csv, natively in Go (see the
+// bench command).
+//
+// A Workload draws search requests from the sample files and a Target runs them, through the API or
+// directly against Redis. Run drives the target with a closed model (VUs ramped through Stages, each
+// sending its next request when the previous one is done) or an open model (a constant arrival rate,
+// whose latencies include the time a request queued for a free VU, so a slow server cannot hide behind
+// fewer requests; arrivals that find the queue full are dropped and counted). Latencies go into HDR
+// histograms, summarized in a JSON Report; Compare tells whether one report is significantly slower than
+// another.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stage ramps the number of VUs linearly to Target over Duration (seconds), like a k6 stage. A stage of
+// zero duration jumps to its target.
+type Stage struct {
+	DurationS float64 `json:"duration_s"`
+	Target    int     `json:"target"`
+}
+
+// ParseStages parses stages written as duration:target pairs, e.g. "10s:10,1m:50,10s:0".
+func ParseStages(s string) ([]Stage, error) {
+	var stages []Stage
+	for _, part := range strings.Split(s, ",") {
+		d, n, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid stage %q (want duration:target, e.g. 30s:10)", part)
+		}
+		duration, err := time.ParseDuration(d)
+		if err != nil || duration < 0 {
+			return nil, fmt.Errorf("invalid stage duration %q", d)
+		}
+		target, err := strconv.Atoi(n)
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("invalid stage target %q", n)
+		}
+		stages = append(stages, Stage{DurationS: duration.Seconds(), Target: target})
+	}
+	return stages, nil
+}
+
+// stagesDuration is the total duration of stages.
+func stagesDuration(stages []Stage) time.Duration {
+	total := 0.0
+	for _, s := range stages {
+		total += s.DurationS
+	}
+	return time.Duration(total * float64(time.Second))
+}
+
+// vusAt returns the number of VUs the stages call for at elapsed, starting from 0.
+func vusAt(stages []Stage, elapsed time.Duration) int {
+	from, t := 0, elapsed.Seconds()
+	for _, s := range stages {
+		if t < s.DurationS {
+			return from + int(float64(s.Target-from)*t/s.DurationS)
+		}
+		t -= s.DurationS
+		from = s.Target
+	}
+	return from
+}
+
+// Options configure a run. Stages select the closed model; Rate (requests per second) with Duration and
+// MaxVUs the open model.
+type Options struct {
+	Stages   []Stage
+	Rate     float64
+	Duration time.Duration
+	MaxVUs   int
+	// Timeout bounds every request.
+	Timeout time.Duration
+	// Seed seeds the request choice of every VU.
+	Seed int64
+	// OnInterval, if set, is called after every second of the run.
+	OnInterval func(Interval)
+}
+
+// Run drives target with requests of w as opts describe and reports the outcome. Cancelling c ends the
+// run early; the requests in flight are still waited for and reported.
+func Run(c context.Context, target Target, w *Workload, opts Options) (*Report, error) {
+	if opts.Timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive")
+	}
+	model := Model{TimeoutS: opts.Timeout.Seconds()}
+	switch {
+	case opts.Rate > 0:
+		if opts.Duration <= 0 || opts.MaxVUs < 1 {
+			return nil, fmt.Errorf("the open model needs a duration and at least one VU")
+		}
+		model.Kind, model.Rate, model.MaxVUs = "open", opts.Rate, opts.MaxVUs
+	case len(opts.Stages) > 0:
+		model.Kind, model.Stages = "closed", opts.Stages
+	default:
+		return nil, fmt.Errorf("set stages (closed model) or a rate (open model)")
+	}
+
+	start := time.Now()
+	rec := newRecorder(start)
+	var vus atomic.Int64
+	done := make(chan struct{})
+	var ticks sync.WaitGroup
+	ticks.Add(1)
+	go func() {
+		defer ticks.Done()
+		t := time.NewTicker(time.Second)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-t.C:
+				iv := rec.tick(now, int(vus.Load()))
+				if opts.OnInterval != nil {
+					opts.OnInterval(iv)
+				}
+			}
+		}
+	}()
+
+	// send runs one request drawn with r, timing it from scheduled.
+	send := func(r *rand.Rand, scheduled time.Time) {
+		req := w.Next(r)
+		rc, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		total, err := target.Do(rc, req)
+		cancel()
+		rec.record(req, time.Since(scheduled), total, err)
+	}
+	var dropped int64
+	if model.Kind == "open" {
+		dropped = runOpen(c, opts, &vus, send)
+	} else {
+		runClosed(c, opts, &vus, send)
+	}
+	close(done)
+	ticks.Wait()
+
+	rep := rec.report(time.Since(start))
+	rep.Target, rep.Model, rep.Dropped = target.Name(), model, dropped
+	return rep, nil
+}
+
+// runClosed ramps VUs through the stages; each VU sends requests back to back.
+func runClosed(c context.Context, opts Options, vus *atomic.Int64, send func(*rand.Rand, time.Time)) {
+	var wg sync.WaitGroup
+	var stops []chan struct{}
+	start := time.Now()
+	end := start.Add(stagesDuration(opts.Stages))
+	t := time.NewTicker(100 * time.Millisecond)
+	defer t.Stop()
+	for id := 0; ; {
+		now := time.Now()
+		want := vusAt(opts.Stages, now.Sub(start))
+		if now.After(end) || c.Err() != nil {
+			want = 0
+		}
+		for len(stops) < want {
+			stop := make(chan struct{})
+			stops = append(stops, stop)
+			r := rand.New(rand.NewSource(opts.Seed + int64(id)))
+			id++
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+					}
+					send(r, time.Now())
+				}
+			}()
+		}
+		for len(stops) > want {
+			close(stops[len(stops)-1])
+			stops = stops[:len(stops)-1]
+		}
+		vus.Store(int64(want))
+		if want == 0 && (now.After(end) || c.Err() != nil) {
+			break
+		}
+		select {
+		case <-t.C:
+		case <-c.Done():
+		}
+	}
+	wg.Wait()
+}
+
+// runOpen schedules requests at opts.Rate for opts.Duration. A request is handed to a free VU or, when all
+// opts.MaxVUs are busy, queued until one is (the wait counts in its latency). Only when opts.MaxVUs
+// requests are already queued is it dropped, and counted. It returns the dropped count.
+func runOpen(c context.Context, opts Options, vus *atomic.Int64, send func(*rand.Rand, time.Time)) int64 {
+	arrivals := make(chan time.Time, opts.MaxVUs)
+	var wg sync.WaitGroup
+	for id := 0; id < opts.MaxVUs; id++ {
+		r := rand.New(rand.NewSource(opts.Seed + int64(id)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for scheduled := range arrivals {
+				vus.Add(1)
+				send(r, scheduled)
+				vus.Add(-1)
+			}
+		}()
+	}
+	var dropped int64
+	start := time.Now()
+	interval := time.Duration(float64(time.Second) / opts.Rate)
+	for sent := int64(0); c.Err() == nil; {
+		elapsed := time.Since(start)
+		if elapsed >= opts.Duration {
+			break
+		}
+		// Hand out every arrival due by now, each stamped with its scheduled time.
+		for due := int64(elapsed.Seconds() * opts.Rate); sent <= due; sent++ {
+			select {
+			case arrivals <- start.Add(time.Duration(sent) * interval):
+			default:
+				dropped++
+			}
+		}
+		time.Sleep(min(max(time.Until(start.Add(time.Duration(sent)*interval)), 0), 10*time.Millisecond))
+	}
+	close(arrivals)
+	wg.Wait()
+	return dropped
+}
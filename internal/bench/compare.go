@@ -0,0 +1,249 @@
+package bench
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Thresholds are the changes Compare tolerates, in percent of the base value (ErrorRate in percentage
+// points). A change past its threshold is only a regression when it is also significant at Confidence,
+// so that the noise between two runs of the same build does not fail a comparison.
+type Thresholds struct {
+	// Latency applies to p50, p90 and p99; Tail to p99.9.
+	Latency    float64
+	Tail       float64
+	Throughput float64
+	ErrorRate  float64
+	Confidence float64
+}
+
+// Verdict is the outcome of comparing one metric.
+type Verdict string
+
+const (
+	VerdictOK        Verdict = "ok"
+	VerdictImproved  Verdict = "improved"
+	VerdictNoise     Verdict = "within noise"
+	VerdictRegressed Verdict = "REGRESSED"
+)
+
+// Comparison is one metric of one scope ("total" or an operation) in two reports. Change is in percent
+// of Base, or in percentage points for the error rate.
+type Comparison struct {
+	Scope     string
+	Metric    string
+	Base      float64
+	New       float64
+	Change    float64
+	Threshold float64
+	Verdict   Verdict
+}
+
+// Compare compares the totals and the operations common to base and next. Latency percentiles are
+// significant when their confidence intervals do not overlap; the interval of a percentile q of n
+// requests spans the ranks n·q ± z·sqrt(n·q·(1-q)), read from the HDR histogram. Total throughput is
+// compared on the per-second intervals (the first and last, partial, left out), the error rate with a
+// two-proportion z-test.
+func Compare(base, next *Report, t Thresholds) ([]Comparison, error) {
+	if t.Confidence <= 0 || t.Confidence >= 1 {
+		return nil, fmt.Errorf("confidence must be between 0 and 1")
+	}
+	z := math.Sqrt2 * math.Erfinv(t.Confidence)
+	rows, err := compareStats("total", &base.Stats, &next.Stats, t, z)
+	if err != nil {
+		return nil, err
+	}
+	// Throughput, for the totals only (operations share the VUs): higher is better, so a drop is the
+	// regression.
+	row := Comparison{Scope: "total", Metric: "throughput_rps", Base: base.Throughput, New: next.Throughput,
+		Change: percentChange(base.Throughput, next.Throughput), Threshold: t.Throughput}
+	significant := meansDiffer(steadyThroughput(base.Intervals), steadyThroughput(next.Intervals), z)
+	row.Verdict = verdict(-row.Change, t.Throughput, significant, significant)
+	rows = append(rows, row)
+
+	var ops []string
+	for op := range base.Operations {
+		if _, ok := next.Operations[op]; ok {
+			ops = append(ops, op)
+		}
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		opRows, err := compareStats(op, base.Operations[op], next.Operations[op], t, z)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, opRows...)
+	}
+	return rows, nil
+}
+
+// compareStats compares the latency percentiles and the error rate of one scope.
+func compareStats(scope string, base, next *Stats, t Thresholds, z float64) ([]Comparison, error) {
+	baseHist, err := decodeStats(base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: base histogram: %v", scope, err)
+	}
+	nextHist, err := decodeStats(next)
+	if err != nil {
+		return nil, fmt.Errorf("%s: new histogram: %v", scope, err)
+	}
+	var rows []Comparison
+	for _, p := range []struct {
+		metric    string
+		q         float64
+		base, new float64
+		threshold float64
+	}{
+		{"p50_ms", 0.5, base.Latency.P50, next.Latency.P50, t.Latency},
+		{"p90_ms", 0.9, base.Latency.P90, next.Latency.P90, t.Latency},
+		{"p99_ms", 0.99, base.Latency.P99, next.Latency.P99, t.Latency},
+		{"p99.9_ms", 0.999, base.Latency.P999, next.Latency.P999, t.Tail},
+	} {
+		row := Comparison{Scope: scope, Metric: p.metric, Base: p.base, New: p.new, Change: percentChange(p.base, p.new), Threshold: p.threshold}
+		worse, better := quantilesDiffer(baseHist, nextHist, p.q, z)
+		row.Verdict = verdict(row.Change, p.threshold, worse, better)
+		rows = append(rows, row)
+	}
+
+	// Error rate, in percent of requests; the change is in percentage points.
+	baseRate, nextRate := errorRate(base), errorRate(next)
+	row := Comparison{Scope: scope, Metric: "error_rate_%", Base: baseRate, New: nextRate, Change: nextRate - baseRate, Threshold: t.ErrorRate}
+	significant := proportionsDiffer(base.Errors, base.Requests, next.Errors, next.Requests, z)
+	row.Verdict = verdict(row.Change, t.ErrorRate, significant, significant)
+	rows = append(rows, row)
+	return rows, nil
+}
+
+// verdict judges a change where an increase is worse: past threshold it is a regression if worse is
+// significant, an improvement below -threshold if better is.
+func verdict(change, threshold float64, worse, better bool) Verdict {
+	switch {
+	case change > threshold && worse:
+		return VerdictRegressed
+	case change > threshold:
+		return VerdictNoise
+	case change < -threshold && better:
+		return VerdictImproved
+	}
+	return VerdictOK
+}
+
+func decodeStats(s *Stats) (*hdrhistogram.Histogram, error) {
+	if s.Histogram == "" {
+		return nil, nil
+	}
+	return s.DecodeHistogram()
+}
+
+// quantilesDiffer tells whether the quantile q of next is significantly above (worse) or below (better)
+// that of base: whether their confidence intervals are apart. Without both histograms any difference counts.
+func quantilesDiffer(base, next *hdrhistogram.Histogram, q, z float64) (worse, better bool) {
+	if base == nil || next == nil || base.TotalCount() == 0 || next.TotalCount() == 0 {
+		return true, true
+	}
+	baseLo, baseHi := quantileInterval(base, q, z)
+	nextLo, nextHi := quantileInterval(next, q, z)
+	return nextLo > baseHi, nextHi < baseLo
+}
+
+// quantileInterval returns the confidence interval of the quantile q of h, in microseconds.
+func quantileInterval(h *hdrhistogram.Histogram, q, z float64) (lo, hi int64) {
+	d := z * math.Sqrt(q*(1-q)/float64(h.TotalCount()))
+	return h.ValueAtQuantile(100 * max(q-d, 0)), h.ValueAtQuantile(100 * min(q+d, 1))
+}
+
+// steadyThroughput returns the throughput of the intervals without the first and last.
+func steadyThroughput(intervals []Interval) []float64 {
+	if len(intervals) <= 2 {
+		return nil
+	}
+	out := make([]float64, 0, len(intervals)-2)
+	for _, iv := range intervals[1 : len(intervals)-1] {
+		out = append(out, iv.Throughput)
+	}
+	return out
+}
+
+// meansDiffer tells whether the means of a and b differ at z standard errors (Welch's approximation).
+// With fewer than 3 values on a side there is no estimate of the noise and any difference counts.
+func meansDiffer(a, b []float64, z float64) bool {
+	if len(a) < 3 || len(b) < 3 {
+		return true
+	}
+	meanA, varA := meanVar(a)
+	meanB, varB := meanVar(b)
+	se := math.Sqrt(varA/float64(len(a)) + varB/float64(len(b)))
+	return math.Abs(meanA-meanB) > z*se
+}
+
+func meanVar(xs []float64) (mean, variance float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, variance / float64(len(xs)-1)
+}
+
+// proportionsDiffer is a two-proportion z-test of e1/n1 against e2/n2.
+func proportionsDiffer(e1, n1, e2, n2 int64, z float64) bool {
+	if n1 == 0 || n2 == 0 {
+		return true
+	}
+	p := float64(e1+e2) / float64(n1+n2)
+	se := math.Sqrt(p * (1 - p) * (1/float64(n1) + 1/float64(n2)))
+	return math.Abs(float64(e2)/float64(n2)-float64(e1)/float64(n1)) > z*se
+}
+
+func errorRate(s *Stats) float64 {
+	if s.Requests == 0 {
+		return 0
+	}
+	return 100 * float64(s.Errors) / float64(s.Requests)
+}
+
+// percentChange is the change from base to next in percent of base; from 0 it is 0 or +Inf.
+func percentChange(base, next float64) float64 {
+	if base == 0 {
+		if next == 0 {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	return 100 * (next - base) / base
+}
+
+// Regressed tells whether any comparison is a regression.
+func Regressed(rows []Comparison) bool {
+	for _, row := range rows {
+		if row.Verdict == VerdictRegressed {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintComparisons writes the comparisons as a table.
+func PrintComparisons(w io.Writer, rows []Comparison) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SCOPE\tMETRIC\tBASE\tNEW\tCHANGE\tTHRESHOLD\tVERDICT")
+	for _, row := range rows {
+		change, threshold := fmt.Sprintf("%+.1f%%", row.Change), fmt.Sprintf("%.1f%%", row.Threshold)
+		switch row.Metric {
+		case "error_rate_%":
+			change, threshold = fmt.Sprintf("%+.3fpp", row.Change), fmt.Sprintf("%.3fpp", row.Threshold)
+		case "throughput_rps":
+			threshold = "-" + threshold
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.3f\t%.3f\t%s\t%s\t%s\n", row.Scope, row.Metric, row.Base, row.New, change, threshold, row.Verdict)
+	}
+	tw.Flush()
+}
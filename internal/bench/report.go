@@ -0,0 +1,316 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/HdrHistogram/hdrhistogram-go"
+)
+
+// Latencies are recorded in microseconds, from 1µs to maxLatency with 3 significant digits; slower
+// requests are recorded as maxLatency.
+const maxLatency = int64(time.Minute / time.Microsecond)
+
+func newHistogram() *hdrhistogram.Histogram {
+	return hdrhistogram.New(1, maxLatency, 3)
+}
+
+// Report is the result of a run, written as JSON by the bench command. The top-level statistics cover
+// all operations; Operations breaks them down by operation, Intervals by second.
+type Report struct {
+	Name        string      `json:"name,omitempty"`
+	Started     time.Time   `json:"started"`
+	DurationS   float64     `json:"duration_s"`
+	Target      string      `json:"target"`
+	Model       Model       `json:"model"`
+	Environment Environment `json:"environment"`
+	Stats
+	// Dropped counts the open-model arrivals that found every VU busy and the queue full, and were not sent.
+	Dropped    int64             `json:"dropped,omitempty"`
+	Operations map[string]*Stats `json:"operations"`
+	Intervals  []Interval        `json:"intervals"`
+}
+
+// Model is the workload of a run: closed (Stages of VUs, each sending its next request when the previous
+// one is done) or open (a constant Rate of arrivals served by at most MaxVUs).
+type Model struct {
+	Kind     string  `json:"kind"`
+	Stages   []Stage `json:"stages,omitempty"`
+	Rate     float64 `json:"rate,omitempty"`
+	MaxVUs   int     `json:"max_vus,omitempty"`
+	TimeoutS float64 `json:"timeout_s"`
+}
+
+// Environment describes the machine that ran the load.
+type Environment struct {
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	CPUs      int    `json:"cpus"`
+	Hostname  string `json:"hostname,omitempty"`
+}
+
+// Stats are the counts and latencies of a set of requests. Latencies are those of successful requests.
+// Histogram is their HDR histogram (microseconds, base64 V2 compressed encoding), so that runs can be
+// merged or compared on more than the listed percentiles.
+type Stats struct {
+	Requests   int64            `json:"requests"`
+	Errors     int64            `json:"errors"`
+	ErrorKinds map[string]int64 `json:"error_kinds,omitempty"`
+	Checks     Checks           `json:"checks"`
+	Throughput float64          `json:"throughput_rps"`
+	Latency    Latency          `json:"latency_ms"`
+	Histogram  string           `json:"histogram"`
+}
+
+// Checks counts successful searches whose result contradicts the sample: a sampled value that found no
+// document, or a miss value (sample_to_csv --miss-ratio) that found some.
+type Checks struct {
+	HitsNotFound int64 `json:"hits_not_found"`
+	MissesFound  int64 `json:"misses_found"`
+}
+
+// Latency summarizes a latency histogram in milliseconds.
+type Latency struct {
+	Min    float64 `json:"min"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	P50    float64 `json:"p50"`
+	P90    float64 `json:"p90"`
+	P99    float64 `json:"p99"`
+	P999   float64 `json:"p99_9"`
+	Max    float64 `json:"max"`
+}
+
+// Interval is one second of a run.
+type Interval struct {
+	T          float64 `json:"t_s"`
+	VUs        int     `json:"vus"`
+	Requests   int64   `json:"requests"`
+	Errors     int64   `json:"errors"`
+	Throughput float64 `json:"throughput_rps"`
+	P50        float64 `json:"p50_ms"`
+	P99        float64 `json:"p99_ms"`
+}
+
+func summarize(h *hdrhistogram.Histogram) Latency {
+	ms := func(us int64) float64 { return float64(us) / 1000 }
+	if h.TotalCount() == 0 {
+		return Latency{}
+	}
+	return Latency{
+		Min:    ms(h.Min()),
+		Mean:   h.Mean() / 1000,
+		StdDev: h.StdDev() / 1000,
+		P50:    ms(h.ValueAtQuantile(50)),
+		P90:    ms(h.ValueAtQuantile(90)),
+		P99:    ms(h.ValueAtQuantile(99)),
+		P999:   ms(h.ValueAtQuantile(99.9)),
+		Max:    ms(h.Max()),
+	}
+}
+
+// DecodeHistogram decodes Stats.Histogram.
+func (s *Stats) DecodeHistogram() (*hdrhistogram.Histogram, error) {
+	return hdrhistogram.Decode([]byte(s.Histogram))
+}
+
+// ReadReport reads a JSON report written by the bench command.
+func ReadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Report
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: not a bench report: %v", path, err)
+	}
+	return &r, nil
+}
+
+// WriteFile writes the report as indented JSON.
+func (r *Report) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Print writes a summary of the report: one line per operation and a total line.
+func (r *Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "%s model against %s, %.1fs\n", r.Model.Kind, r.Target, r.DurationS)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "OPERATION\tREQUESTS\tERRORS\tRPS\tP50_MS\tP90_MS\tP99_MS\tP99.9_MS\tMAX_MS\t")
+	row := func(name string, s *Stats) {
+		l := s.Latency
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t\n",
+			name, s.Requests, s.Errors, s.Throughput, l.P50, l.P90, l.P99, l.P999, l.Max)
+	}
+	names := make([]string, 0, len(r.Operations))
+	for name := range r.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row(name, r.Operations[name])
+	}
+	row("total", &r.Stats)
+	tw.Flush()
+	if len(r.ErrorKinds) > 0 {
+		kinds := make([]string, 0, len(r.ErrorKinds))
+		for kind := range r.ErrorKinds {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		fmt.Fprint(w, "Errors:")
+		for _, kind := range kinds {
+			fmt.Fprintf(w, " %s=%d", kind, r.ErrorKinds[kind])
+		}
+		fmt.Fprintln(w)
+	}
+	if r.Checks != (Checks{}) {
+		fmt.Fprintf(w, "Failed checks: %d sampled values found nothing, %d misses found documents\n",
+			r.Checks.HitsNotFound, r.Checks.MissesFound)
+	}
+	if r.Dropped > 0 {
+		fmt.Fprintf(w, "Dropped: %d arrivals found every VU busy and the queue full (raise --max-vus)\n", r.Dropped)
+	}
+}
+
+// recorder collects the outcome of every request, safe for concurrent use.
+type recorder struct {
+	mu  sync.Mutex
+	ops map[string]*opRecorder
+	all *opRecorder
+	// The current interval.
+	intervalStart    time.Time
+	interval         *hdrhistogram.Histogram
+	intervalRequests int64
+	intervalErrors   int64
+	intervals        []Interval
+	start            time.Time
+}
+
+type opRecorder struct {
+	hist       *hdrhistogram.Histogram
+	requests   int64
+	errors     int64
+	errorKinds map[string]int64
+	checks     Checks
+}
+
+func newOpRecorder() *opRecorder {
+	return &opRecorder{hist: newHistogram(), errorKinds: map[string]int64{}}
+}
+
+func (o *opRecorder) record(req Request, us int64, total int64, kind string) {
+	o.requests++
+	if kind != "" {
+		o.errors++
+		o.errorKinds[kind]++
+		return
+	}
+	_ = o.hist.RecordValue(us)
+	switch {
+	case req.Miss && total > 0:
+		o.checks.MissesFound++
+	case !req.Miss && total == 0:
+		o.checks.HitsNotFound++
+	}
+}
+
+func (o *opRecorder) stats(seconds float64) *Stats {
+	s := &Stats{Requests: o.requests, Errors: o.errors, Checks: o.checks, Latency: summarize(o.hist)}
+	if len(o.errorKinds) > 0 {
+		s.ErrorKinds = o.errorKinds
+	}
+	if seconds > 0 {
+		s.Throughput = float64(o.requests) / seconds
+	}
+	if encoded, err := o.hist.Encode(hdrhistogram.V2CompressedEncodingCookieBase); err == nil {
+		s.Histogram = string(encoded)
+	}
+	return s
+}
+
+func newRecorder(start time.Time) *recorder {
+	return &recorder{ops: map[string]*opRecorder{}, all: newOpRecorder(), interval: newHistogram(), intervalStart: start, start: start}
+}
+
+// record adds a request that took latency and found total documents, or failed with err.
+func (r *recorder) record(req Request, latency time.Duration, total int64, err error) {
+	us := min(max(latency.Microseconds(), 1), maxLatency)
+	kind := ""
+	if err != nil {
+		kind = errorKind(err)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	op, ok := r.ops[req.Op]
+	if !ok {
+		op = newOpRecorder()
+		r.ops[req.Op] = op
+	}
+	op.record(req, us, total, kind)
+	r.all.record(req, us, total, kind)
+	r.intervalRequests++
+	if kind != "" {
+		r.intervalErrors++
+	} else {
+		_ = r.interval.RecordValue(us)
+	}
+}
+
+// tick closes the current interval at now, with vus running, and returns it.
+func (r *recorder) tick(now time.Time, vus int) Interval {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	iv := Interval{
+		T:        now.Sub(r.start).Seconds(),
+		VUs:      vus,
+		Requests: r.intervalRequests,
+		Errors:   r.intervalErrors,
+		P50:      float64(r.interval.ValueAtQuantile(50)) / 1000,
+		P99:      float64(r.interval.ValueAtQuantile(99)) / 1000,
+	}
+	if d := now.Sub(r.intervalStart).Seconds(); d > 0 {
+		iv.Throughput = float64(iv.Requests) / d
+	}
+	r.intervals = append(r.intervals, iv)
+	r.interval.Reset()
+	r.intervalRequests, r.intervalErrors, r.intervalStart = 0, 0, now
+	return iv
+}
+
+// report builds the report of a run that lasted d.
+func (r *recorder) report(d time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hostname, _ := os.Hostname()
+	rep := &Report{
+		Started:   r.start,
+		DurationS: d.Seconds(),
+		Environment: Environment{
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+			CPUs:      runtime.NumCPU(),
+			Hostname:  hostname,
+		},
+		Stats:      *r.all.stats(d.Seconds()),
+		Operations: map[string]*Stats{},
+		Intervals:  r.intervals,
+	}
+	for name, op := range r.ops {
+		rep.Operations[name] = op.stats(d.Seconds())
+	}
+	return rep
+}
@@ -0,0 +1,138 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// Target runs requests and returns the number of matching documents.
+type Target interface {
+	Do(c context.Context, req Request) (total int64, err error)
+	// Name describes the target in reports.
+	Name() string
+}
+
+// HTTPTarget sends requests to the API: GET /search_customers or /search_events with the field as a
+// query parameter.
+type HTTPTarget struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPTarget returns a target for the API at baseURL, keeping up to conns connections open and failing
+// requests that take longer than timeout.
+func NewHTTPTarget(baseURL string, conns int, timeout time.Duration) *HTTPTarget {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = conns
+	transport.MaxIdleConnsPerHost = conns
+	return &HTTPTarget{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Transport: transport, Timeout: timeout},
+	}
+}
+
+func (t *HTTPTarget) Name() string {
+	return "api " + t.baseURL
+}
+
+func (t *HTTPTarget) Do(c context.Context, req Request) (int64, error) {
+	u := t.baseURL + "/" + req.Op + "?" + url.Values{req.Field: {req.Value}}.Encode()
+	httpReq, err := http.NewRequestWithContext(c, http.MethodGet, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	res, err := t.client.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, &StatusError{Code: res.StatusCode}
+	}
+	var page struct {
+		Total int64 `json:"total"`
+	}
+	if err := json.Unmarshal(body, &page); err != nil {
+		return 0, fmt.Errorf("invalid response: %v", err)
+	}
+	return page.Total, nil
+}
+
+// RedisTarget runs the search the API would run for the first page of a request directly against Redis,
+// leaving the API out of the measurement.
+type RedisTarget struct {
+	client *redis.Client
+}
+
+// NewRedisTarget returns a target for the Redis at redisURL, with a pool of up to conns connections. Like
+// HTTPTarget's, requests fail after timeout (the deadline of their context): the client's usual read
+// timeout is replaced so that it neither cuts requests shorter nor lets them run longer.
+func NewRedisTarget(redisURL string, conns int, timeout time.Duration) (*RedisTarget, error) {
+	opt, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	opt.PoolSize = conns
+	opt.DialTimeout = 5 * time.Second
+	opt.ReadTimeout, opt.WriteTimeout, opt.PoolTimeout = timeout, timeout, timeout
+	opt.ContextTimeoutEnabled = true
+	return &RedisTarget{client: redis.NewClient(opt)}, nil
+}
+
+// Close closes the target's connections.
+func (t *RedisTarget) Close() error {
+	return t.client.Close()
+}
+
+func (t *RedisTarget) Name() string {
+	return "redis " + t.client.Options().Addr
+}
+
+func (t *RedisTarget) Do(c context.Context, req Request) (int64, error) {
+	q, err := query.Build(req.Index, map[string]string{req.Field: req.Value})
+	if err != nil {
+		return 0, err
+	}
+	page, err := redisutil.AggregatePage(c, t.client, req.Index.Name, q, redisutil.SearchOptions{Limit: 10})
+	return page.Total, err
+}
+
+// StatusError is an HTTP response other than 200 OK.
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("HTTP %d", e.Code)
+}
+
+// errorKind groups errors for the report: http_<code>, timeout, connection or other.
+func errorKind(err error) string {
+	var status *StatusError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &status):
+		return fmt.Sprintf("http_%d", status.Code)
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, redis.ErrPoolTimeout), errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, new(*net.OpError)), errors.Is(err, redis.ErrClosed):
+		return "connection"
+	}
+	return "other"
+}
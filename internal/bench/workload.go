@@ -0,0 +1,162 @@
+package bench
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// Operation names, as the API routes they exercise.
+const (
+	OpSearchCustomers = "search_customers"
+	OpSearchEvents    = "search_events"
+)
+
+// Request is one search: documents of Index whose Field equals Value. Miss marks a value made to match
+// nothing (sample_to_csv --miss-ratio).
+type Request struct {
+	Op    string
+	Index *schema.Index
+	Field string
+	Value string
+	Miss  bool
+}
+
+// record is one row of a sample: the searchable fields that have a value.
+type record struct {
+	fields []string
+	values []string
+	miss   bool
+}
+
+// samples are the records of one sample file and the operation searching them.
+type samples struct {
+	op      string
+	idx     *schema.Index
+	records []record
+}
+
+// Workload draws requests from the sample files of sample_to_csv.
+type Workload struct {
+	customers samples
+	events    samples
+	// customerShare is the fraction of requests searching customers.
+	customerShare float64
+}
+
+// NewWorkload loads the customer and event samples (either path may be "" to leave that type out) and
+// returns a workload sending customerShare of its requests to customers, as the k6 script does. Columns
+// that are not fields of the index (other than key and miss) cannot be searched and are returned as
+// ignored.
+func NewWorkload(customersPath, eventsPath string, customerShare float64) (*Workload, []string, error) {
+	if customerShare < 0 || customerShare > 1 {
+		return nil, nil, fmt.Errorf("customer share must be between 0 and 1")
+	}
+	reg := schema.Get()
+	w := &Workload{customerShare: customerShare}
+	w.customers.op, w.customers.idx = OpSearchCustomers, reg.MustIndex(schema.CustomerIndex)
+	w.events.op, w.events.idx = OpSearchEvents, reg.MustIndex(schema.EventIndex)
+	var ignored []string
+	for _, s := range []struct {
+		path string
+		into *samples
+	}{{customersPath, &w.customers}, {eventsPath, &w.events}} {
+		if s.path == "" {
+			continue
+		}
+		records, skipped, err := loadSamples(s.path, s.into.idx)
+		if err != nil {
+			return nil, nil, err
+		}
+		s.into.records = records
+		for _, col := range skipped {
+			ignored = append(ignored, s.path+": "+col)
+		}
+	}
+	switch {
+	case len(w.customers.records) == 0 && len(w.events.records) == 0:
+		return nil, ignored, fmt.Errorf("no searchable records in the samples")
+	case len(w.customers.records) == 0:
+		w.customerShare = 0
+	case len(w.events.records) == 0:
+		w.customerShare = 1
+	}
+	return w, ignored, nil
+}
+
+// loadSamples reads a sample CSV with a header line. Records without any searchable value are left out.
+func loadSamples(path string, idx *schema.Index) ([]record, []string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	header, err := r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("%s: %v", path, err)
+	}
+	missCol := -1
+	var cols []int
+	var skipped []string
+	for i, name := range header {
+		switch _, ok := idx.Field(name); {
+		case name == "key":
+		case name == "miss":
+			missCol = i
+		case ok:
+			cols = append(cols, i)
+		default:
+			skipped = append(skipped, name)
+		}
+	}
+	var records []record
+	for {
+		row, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %v", path, err)
+		}
+		var rec record
+		for _, i := range cols {
+			if i < len(row) && row[i] != "" && row[i] != "null" {
+				rec.fields = append(rec.fields, header[i])
+				rec.values = append(rec.values, row[i])
+			}
+		}
+		if len(rec.fields) == 0 {
+			continue
+		}
+		rec.miss = missCol >= 0 && missCol < len(row) && row[missCol] == "true"
+		records = append(records, rec)
+	}
+	return records, skipped, nil
+}
+
+// Records returns how many customer and event records the workload draws from.
+func (w *Workload) Records() (customers, events int) {
+	return len(w.customers.records), len(w.events.records)
+}
+
+// Next draws a request: a random record of a type picked by the customer share, searched by one of its
+// fields at random.
+func (w *Workload) Next(r *rand.Rand) Request {
+	s := &w.events
+	if r.Float64() < w.customerShare {
+		s = &w.customers
+	}
+	rec := s.records[r.Intn(len(s.records))]
+	i := r.Intn(len(rec.fields))
+	return Request{Op: s.op, Index: s.idx, Field: rec.fields[i], Value: rec.values[i], Miss: rec.miss}
+}
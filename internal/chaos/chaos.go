@@ -0,0 +1,220 @@
+// Package chaos records generation runs with a chaos profile and reports on them.
+//
+// A generation run with a chaos profile (see faker.Chaos) leaves a Run at chaos:<prefix>: what was
+// injected, a sample of the broken documents and the index's hash_indexing_failures before the run. The
+// report compares that with FT.INFO afterwards and searches for every sample, by its document ID (was it
+// indexed at all?) and by its malformed value (can it be found by the field that was broken?). Only the
+// latest run per prefix is kept.
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNoRun is returned when no chaos run has been recorded for a prefix.
+var ErrNoRun = errors.New("no chaos run recorded")
+
+// searchLimit caps the keys read back when looking for a sample among the matches of a search.
+const searchLimit = 1000
+
+// Run records a generation run with a chaos profile.
+type Run struct {
+	Index          string                       `json:"index"`
+	Prefix         string                       `json:"prefix"`
+	IDField        string                       `json:"id_field"`
+	Documents      int                          `json:"documents"`
+	Profile        faker.ChaosProfile           `json:"profile"`
+	Injected       map[faker.ChaosVariant]int64 `json:"injected"`
+	Samples        []faker.ChaosMutation        `json:"samples"`
+	FailuresBefore int64                        `json:"hash_indexing_failures_before"`
+	StartedAt      time.Time                    `json:"started_at"`
+}
+
+// RunKey returns the key a chaos run for prefix is kept at.
+func RunKey(prefix string) string {
+	return "chaos:" + strings.TrimSuffix(prefix, ":")
+}
+
+// NewRun starts recording a chaos run of documents documents. It reads hash_indexing_failures first,
+// so call it before writing any document; a missing index counts as no failures.
+func NewRun(client *redis.Client, chaos *faker.Chaos, documents int) (*Run, error) {
+	idx := chaos.Index()
+	run := &Run{Index: idx.Name, Prefix: idx.Prefix, IDField: chaos.IDField(), Documents: documents, Profile: chaos.Profile(), StartedAt: time.Now().UTC()}
+	info, err := redisutil.GetIndexInfo(client, idx.Name)
+	if err != nil && !redisutil.IsUnknownIndex(err) {
+		return nil, err
+	}
+	run.FailuresBefore = info.IndexingFailures
+	return run, nil
+}
+
+// Finish copies what chaos injected into the run and stores it at RunKey.
+func (r *Run) Finish(c context.Context, client *redis.Client, chaos *faker.Chaos) error {
+	r.Injected = chaos.Counts()
+	r.Samples = chaos.Samples()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return client.Set(c, RunKey(r.Prefix), data, 0).Err()
+}
+
+// LoadRun returns the latest chaos run for prefix, or ErrNoRun.
+func LoadRun(c context.Context, client *redis.Client, prefix string) (Run, error) {
+	var run Run
+	data, err := client.Get(c, RunKey(prefix)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return run, fmt.Errorf("%w for %s", ErrNoRun, prefix)
+	}
+	if err != nil {
+		return run, err
+	}
+	return run, json.Unmarshal(data, &run)
+}
+
+// Report describes how the documents of a chaos run were indexed and how they behave in search.
+// NewIndexingFailures is the growth of hash_indexing_failures since the run started: documents RediSearch
+// refused to index, which are missing from every search.
+type Report struct {
+	Index                    string             `json:"index"`
+	Documents                int                `json:"documents"`
+	Injected                 int64              `json:"injected"`
+	IndexingFailuresBefore   int64              `json:"hash_indexing_failures_before"`
+	IndexingFailuresAfter    int64              `json:"hash_indexing_failures_after"`
+	NewIndexingFailures      int64              `json:"new_indexing_failures"`
+	LastIndexingError        string             `json:"last_indexing_error,omitempty"`
+	LastIndexingErrorKey     string             `json:"last_indexing_error_key,omitempty"`
+	LastIndexingErrorVariant faker.ChaosVariant `json:"last_indexing_error_variant,omitempty"`
+	Variants                 []VariantReport    `json:"variants"`
+}
+
+// VariantReport sums up the samples of one variant. Indexed samples are found when searching for their
+// document ID; Searchable samples are also found when searching for their malformed value.
+type VariantReport struct {
+	Variant      faker.ChaosVariant `json:"variant"`
+	Injected     int64              `json:"injected"`
+	Sampled      int                `json:"sampled"`
+	Indexed      int                `json:"indexed"`
+	NotIndexed   int                `json:"not_indexed"`
+	Searchable   int                `json:"searchable"`
+	SearchErrors int                `json:"search_errors"`
+	Samples      []SampleResult     `json:"samples"`
+}
+
+// SampleResult is the outcome for one sample. Matches is the number of documents the value search
+// found (for duplicate_id, every document sharing the ID). Values are shortened to 80 characters.
+type SampleResult struct {
+	Key        string      `json:"key"`
+	Field      string      `json:"field"`
+	Value      interface{} `json:"value,omitempty"`
+	Indexed    bool        `json:"indexed"`
+	Searchable *bool       `json:"searchable,omitempty"`
+	Matches    int64       `json:"matches,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// BuildReport waits for idx to finish any background indexing and reports on run.
+func BuildReport(c context.Context, client *redis.Client, idx *schema.Index, run Run) (Report, error) {
+	report := Report{Index: idx.Name, Documents: run.Documents, IndexingFailuresBefore: run.FailuresBefore}
+	if err := redisutil.WaitForIndexing(c, client, idx.Name, nil); err != nil {
+		return report, err
+	}
+	info, err := redisutil.GetIndexInfo(client, idx.Name)
+	if err != nil {
+		return report, err
+	}
+	report.IndexingFailuresAfter = info.IndexingFailures
+	report.NewIndexingFailures = info.IndexingFailures - run.FailuresBefore
+	report.LastIndexingError = info.LastError
+	report.LastIndexingErrorKey = info.LastErrorKey
+	for _, variant := range faker.ChaosVariants {
+		injected := run.Injected[variant]
+		report.Injected += injected
+		var samples []faker.ChaosMutation
+		for _, s := range run.Samples {
+			if s.Variant == variant {
+				samples = append(samples, s)
+			}
+			if s.Key == info.LastErrorKey {
+				report.LastIndexingErrorVariant = s.Variant
+			}
+		}
+		if injected == 0 && len(samples) == 0 {
+			continue
+		}
+		vr := VariantReport{Variant: variant, Injected: injected, Sampled: len(samples)}
+		for _, s := range samples {
+			result := checkSample(client, idx, run.IDField, s)
+			if result.Indexed {
+				vr.Indexed++
+			} else {
+				vr.NotIndexed++
+			}
+			if result.Searchable != nil && *result.Searchable {
+				vr.Searchable++
+			}
+			if result.Error != "" {
+				vr.SearchErrors++
+			}
+			vr.Samples = append(vr.Samples, result)
+		}
+		report.Variants = append(report.Variants, vr)
+	}
+	return report, nil
+}
+
+// checkSample searches for one sample by its document ID and, when the mutation left a value
+// behind, by that value.
+func checkSample(client *redis.Client, idx *schema.Index, idField string, s faker.ChaosMutation) SampleResult {
+	result := SampleResult{Key: s.Key, Field: s.Field, Value: shorten(s.Value)}
+	if s.ID != "" {
+		q, err := query.Build(idx, map[string]string{idField: s.ID})
+		if err == nil {
+			var keys []string
+			_, keys, err = redisutil.SearchKeys(client, idx.Name, q, searchLimit)
+			result.Indexed = slices.Contains(keys, s.Key)
+		}
+		if err != nil {
+			result.Error = "id search: " + err.Error()
+		}
+	}
+	if s.Value == nil {
+		return result
+	}
+	value := fmt.Sprint(s.Value)
+	if f, ok := s.Value.(float64); ok {
+		// Numbers come back from JSON as floats; search for them as they were written.
+		value = fmt.Sprintf("%.0f", f)
+	}
+	q, err := query.Build(idx, map[string]string{s.Field: value})
+	if err == nil {
+		var keys []string
+		result.Matches, keys, err = redisutil.SearchKeys(client, idx.Name, q, searchLimit)
+		found := slices.Contains(keys, s.Key)
+		result.Searchable = &found
+	}
+	if err != nil {
+		result.Error = "value search: " + err.Error()
+	}
+	return result
+}
+
+func shorten(v interface{}) interface{} {
+	s, ok := v.(string)
+	if !ok || len([]rune(s)) <= 80 {
+		return v
+	}
+	return fmt.Sprintf("%s... (%d bytes)", string([]rune(s)[:80]), len(s))
+}
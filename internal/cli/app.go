@@ -15,12 +15,21 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(commands.GenerateCustomersCmd)
 	rootCmd.AddCommand(commands.GenerateEventsCmd)
+	rootCmd.AddCommand(commands.GenerateLinkedCmd)
+	rootCmd.AddCommand(commands.GenerateCmd)
+	rootCmd.AddCommand(commands.ChaosReportCmd)
+	rootCmd.AddCommand(commands.ReplayCmd)
+	rootCmd.AddCommand(commands.ExportCmd)
+	rootCmd.AddCommand(commands.ImportCmd)
 	rootCmd.AddCommand(commands.CreateIndexesCmd)
+	rootCmd.AddCommand(commands.RollbackIndexesCmd)
+	rootCmd.AddCommand(commands.IndexesCmd)
 	rootCmd.AddCommand(commands.SearchCustomersCmd)
 	rootCmd.AddCommand(commands.SearchEventsCmd)
 	rootCmd.AddCommand(commands.CustomerCmd)
 	rootCmd.AddCommand(commands.EventCmd)
 	rootCmd.AddCommand(commands.SampleToCSVCommand)
+	rootCmd.AddCommand(commands.BenchCmd)
 
 }
 
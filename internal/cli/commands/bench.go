@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/bench"
+	"github.com/spf13/cobra"
+)
+
+// BenchCmd replays the sample CSVs of sample_to_csv against the search endpoints or Redis, with a closed
+// model (--vus/--duration or --stages) or an open model (--rate), and reports latency percentiles, errors
+// and throughput.
+var BenchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Load-test customer and event search with the sample CSVs and write a JSON report",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		targetName, _ := cmd.Flags().GetString("target")
+		baseURL, _ := cmd.Flags().GetString("url")
+		customers, _ := cmd.Flags().GetString("customers")
+		events, _ := cmd.Flags().GetString("events")
+		share, _ := cmd.Flags().GetFloat64("customer-share")
+		vus, _ := cmd.Flags().GetInt("vus")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		stageList, _ := cmd.Flags().GetString("stages")
+		rate, _ := cmd.Flags().GetFloat64("rate")
+		maxVUs, _ := cmd.Flags().GetInt("max-vus")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+		reportPath, _ := cmd.Flags().GetString("report")
+		name, _ := cmd.Flags().GetString("name")
+		seed := time.Now().UnixNano()
+		if cmd.Flags().Changed("seed") {
+			seed, _ = cmd.Flags().GetInt64("seed")
+		}
+
+		opts := bench.Options{Rate: rate, Duration: duration, MaxVUs: maxVUs, Timeout: timeout, Seed: seed}
+		switch {
+		case rate < 0:
+			fmt.Println("Invalid options: --rate must not be negative")
+			os.Exit(1)
+		case rate > 0 && stageList != "":
+			fmt.Println("Invalid options: --stages (closed model) and --rate (open model) are exclusive")
+			os.Exit(1)
+		case rate > 0 && maxVUs < 1:
+			fmt.Println("Invalid options: --max-vus must be at least 1")
+			os.Exit(1)
+		case stageList != "":
+			stages, err := bench.ParseStages(stageList)
+			if err != nil {
+				fmt.Println("Invalid options:", err)
+				os.Exit(1)
+			}
+			opts.Stages = stages
+		case rate == 0:
+			if vus < 1 {
+				fmt.Println("Invalid options: --vus must be at least 1")
+				os.Exit(1)
+			}
+			opts.Stages = []bench.Stage{{Target: vus}, {DurationS: duration.Seconds(), Target: vus}}
+		}
+		if duration <= 0 && stageList == "" {
+			fmt.Println("Invalid options: --duration must be positive")
+			os.Exit(1)
+		}
+
+		workload, ignored, err := bench.NewWorkload(customers, events, share)
+		for _, col := range ignored {
+			fmt.Printf("Ignoring column %s: not a field of its index\n", col)
+		}
+		if err != nil {
+			fmt.Println("Error loading samples:", err)
+			os.Exit(1)
+		}
+		nCustomers, nEvents := workload.Records()
+		fmt.Printf("Loaded %d customer and %d event records\n", nCustomers, nEvents)
+
+		var target bench.Target
+		conns := max(vus, maxVUs)
+		for _, s := range opts.Stages {
+			conns = max(conns, s.Target)
+		}
+		switch targetName {
+		case "api":
+			target = bench.NewHTTPTarget(baseURL, conns, timeout)
+		case "redis":
+			redisURL := os.Getenv("REDIS_URL")
+			if redisURL == "" {
+				redisURL = "redis://localhost:6379/0"
+			}
+			redisTarget, err := bench.NewRedisTarget(redisURL, conns, timeout)
+			if err != nil {
+				fmt.Println("Error creating Redis client:", err)
+				os.Exit(1)
+			}
+			defer redisTarget.Close()
+			target = redisTarget
+		default:
+			fmt.Println("Invalid options: --target must be api or redis")
+			os.Exit(1)
+		}
+
+		// Ctrl-C ends the run early but still reports it.
+		c, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		opts.OnInterval = func(iv bench.Interval) {
+			if int(iv.T)%5 == 0 {
+				fmt.Printf("%4.0fs  vus=%d  rps=%.0f  p50=%.2fms  p99=%.2fms  errors=%d\n",
+					iv.T, iv.VUs, iv.Throughput, iv.P50, iv.P99, iv.Errors)
+			}
+		}
+		report, err := bench.Run(c, target, workload, opts)
+		if err != nil {
+			fmt.Println("Error running benchmark:", err)
+			os.Exit(1)
+		}
+		report.Name = name
+		report.Print(os.Stdout)
+		if reportPath != "" {
+			if err := report.WriteFile(reportPath); err != nil {
+				fmt.Println("Error writing report:", err)
+				os.Exit(1)
+			}
+			fmt.Println("Report written to", reportPath)
+		}
+	},
+}
+
+// benchCompareCmd compares two bench reports and fails past the thresholds, to gate changes on performance.
+var benchCompareCmd = &cobra.Command{
+	Use:   "compare base.json new.json",
+	Short: "Compare two bench reports and exit non-zero on a significant regression",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		var t bench.Thresholds
+		t.Latency, _ = cmd.Flags().GetFloat64("latency-threshold")
+		t.Tail, _ = cmd.Flags().GetFloat64("tail-threshold")
+		t.Throughput, _ = cmd.Flags().GetFloat64("throughput-threshold")
+		t.ErrorRate, _ = cmd.Flags().GetFloat64("error-rate-threshold")
+		t.Confidence, _ = cmd.Flags().GetFloat64("confidence")
+		base, err := bench.ReadReport(args[0])
+		if err != nil {
+			fmt.Println("Error reading report:", err)
+			os.Exit(1)
+		}
+		next, err := bench.ReadReport(args[1])
+		if err != nil {
+			fmt.Println("Error reading report:", err)
+			os.Exit(1)
+		}
+		rows, err := bench.Compare(base, next, t)
+		if err != nil {
+			fmt.Println("Invalid options:", err)
+			os.Exit(1)
+		}
+		for i, r := range []*bench.Report{base, next} {
+			label := r.Name
+			if label == "" {
+				label = r.Started.Format(time.RFC3339)
+			}
+			fmt.Printf("%-5s %s: %s model against %s, %d requests in %.1fs\n",
+				[]string{"base", "new"}[i], label, r.Model.Kind, r.Target, r.Requests, r.DurationS)
+		}
+		if base.Model.Kind != next.Model.Kind || base.Target != next.Target {
+			fmt.Println("Warning: the runs differ in model or target; the comparison may not be meaningful")
+		}
+		bench.PrintComparisons(os.Stdout, rows)
+		if bench.Regressed(rows) {
+			fmt.Println("Performance regressed past the thresholds")
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	BenchCmd.AddCommand(benchCompareCmd)
+	benchCompareCmd.Flags().Float64("latency-threshold", 10, "Largest tolerated p50/p90/p99 increase, in percent")
+	benchCompareCmd.Flags().Float64("tail-threshold", 25, "Largest tolerated p99.9 increase, in percent")
+	benchCompareCmd.Flags().Float64("throughput-threshold", 10, "Largest tolerated throughput drop, in percent")
+	benchCompareCmd.Flags().Float64("error-rate-threshold", 0.1, "Largest tolerated error rate increase, in percentage points")
+	benchCompareCmd.Flags().Float64("confidence", 0.95, "Confidence a change past a threshold must be significant at to count as a regression")
+
+	BenchCmd.Flags().String("target", "api", "What to load: api (the search endpoints) or redis (the same search, directly; uses REDIS_URL)")
+	BenchCmd.Flags().String("url", "http://localhost:8080", "API base URL for --target api")
+	BenchCmd.Flags().String("customers", "perf/customer_sample.csv", "Customer sample CSV from sample_to_csv (empty to leave customers out)")
+	BenchCmd.Flags().String("events", "perf/event_sample.csv", "Event sample CSV from sample_to_csv (empty to leave events out)")
+	BenchCmd.Flags().Float64("customer-share", 0.5, "Fraction of requests searching customers; the rest search events")
+	BenchCmd.Flags().Int("vus", 10, "Closed model: concurrent virtual users, each sending requests back to back")
+	BenchCmd.Flags().Duration("duration", 30*time.Second, "How long to run with --vus or --rate")
+	BenchCmd.Flags().String("stages", "", "Closed model: VU ramp as duration:target pairs, e.g. 10s:10,10s:50,60s:50 (overrides --vus and --duration)")
+	BenchCmd.Flags().Float64("rate", 0, "Open model: requests started per second, whatever the latency")
+	BenchCmd.Flags().Int("max-vus", 100, "Open model: most requests in flight; as many more wait in a queue, and arrivals beyond are dropped and counted")
+	BenchCmd.Flags().Duration("timeout", 10*time.Second, "Timeout of each request")
+	BenchCmd.Flags().String("report", "", "Write the JSON report (percentiles, errors, throughput, HDR histograms) to this file")
+	BenchCmd.Flags().String("name", "", "Label stored in the report, e.g. a commit or branch")
+	BenchCmd.Flags().Int64("seed", 0, "Seed for a reproducible request sequence per VU (default: random)")
+}
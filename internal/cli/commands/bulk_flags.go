@@ -0,0 +1,112 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+)
+
+// addBulkFlags registers the bulk writer, key and seed flags shared by the generate commands.
+func addBulkFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("batch-size", redisutil.DefaultBulkBatchSize, "Documents per pipelined round trip")
+	cmd.Flags().Int("workers", 0, "Concurrent pipelines (default: number of CPUs)")
+	cmd.Flags().Int("max-retries", redisutil.DefaultBulkMaxRetries, "Retries of a batch's transient failures, at most 10 (0 to turn retries off)")
+	cmd.Flags().Bool("mset", false, "Write each batch with a single JSON.MSET (RedisJSON 2.6+) instead of pipelined JSON.SET")
+	cmd.Flags().String("keys", string(redisutil.KeySequential), "Key strategy: sequential (overwrites earlier runs), counter, ulid, uuid or docid")
+	cmd.Flags().Int64("seed", 0, "Seed for reproducible data: the same seed and count generate identical documents (default: random)")
+}
+
+// addEventMixFlag registers --event-mix on the commands that generate events.
+func addEventMixFlag(cmd *cobra.Command) {
+	cmd.Flags().String("event-mix", "", "Event types and relative weights, e.g. page_view=70,purchase=30 (default: the built-in mix of page_view, chat_message, call_started, form_submit, ticket_opened and purchase)")
+}
+
+// generatorFromFlags returns a generator for --seed, or a randomly seeded one when the flag is not set,
+// drawing event types from --event-mix when the command has it. It exits on an invalid mix.
+func generatorFromFlags(cmd *cobra.Command) *faker.Generator {
+	var generator *faker.Generator
+	if !cmd.Flags().Changed("seed") {
+		generator = faker.NewRandomGenerator()
+	} else {
+		seed, _ := cmd.Flags().GetInt64("seed")
+		fmt.Println("Seed:", seed)
+		generator = faker.NewGenerator(seed)
+	}
+	if cmd.Flags().Lookup("event-mix") == nil {
+		return generator
+	}
+	mix, _ := cmd.Flags().GetString("event-mix")
+	events, err := faker.DefaultEventCatalogue().Mix(mix)
+	if err != nil {
+		fmt.Println("Invalid options:", err)
+		os.Exit(1)
+	}
+	return generator.WithEvents(events)
+}
+
+// keyAllocatorFromFlags returns the key allocator selected by --keys. It exits on an unknown strategy or
+// when the counter block cannot be reserved.
+func keyAllocatorFromFlags(cmd *cobra.Command, client *redis.Client, prefix string, count int) *redisutil.KeyAllocator {
+	name, _ := cmd.Flags().GetString("keys")
+	strategy, err := redisutil.ParseKeyStrategy(name)
+	if err != nil {
+		fmt.Println("Invalid options:", err)
+		os.Exit(1)
+	}
+	keys, err := redisutil.NewKeyAllocator(context.Background(), client, strategy, prefix, count)
+	if err != nil {
+		fmt.Println("Error allocating keys:", err)
+		os.Exit(1)
+	}
+	return keys
+}
+
+// bulkOptionsFromFlags reads the bulk writer flags and prints progress every 10% of total.
+// It exits on invalid values.
+func bulkOptionsFromFlags(cmd *cobra.Command, what string, total int) redisutil.BulkOptions {
+	var opts redisutil.BulkOptions
+	opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+	opts.Workers, _ = cmd.Flags().GetInt("workers")
+	retries, _ := cmd.Flags().GetInt("max-retries")
+	opts.MaxRetries = &retries
+	opts.UseMSET, _ = cmd.Flags().GetBool("mset")
+	if err := opts.Validate(); err != nil {
+		fmt.Println("Invalid options:", err)
+		os.Exit(1)
+	}
+	var mu sync.Mutex
+	next := int64(total / 10)
+	opts.OnProgress = func(written, failed int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if written+failed >= next && written+failed < int64(total) {
+			fmt.Printf("Stored %d %s...\n", written, what)
+			next = written + failed + int64(total/10)
+		}
+	}
+	return opts
+}
+
+// printBulkStats prints the outcome of a bulk write.
+func printBulkStats(stats redisutil.BulkStats, what string) {
+	fmt.Printf("Done. Stored %d %s in Redis in %s (%.0f docs/s, %d batches, %d retries, %d failed).\n",
+		stats.Written, what, stats.Elapsed.Round(time.Millisecond), stats.DocsPerSec, stats.Batches, stats.Retries, stats.Failed)
+	for _, keys := range stats.Keys {
+		fmt.Printf("Keys: %s\n", keys)
+	}
+	printBatchErrors(stats.Errors)
+}
+
+// printBatchErrors prints the batch errors of a bulk write, replay or export.
+func printBatchErrors(errs []redisutil.BatchError) {
+	for _, e := range errs {
+		fmt.Printf("  batch %d (first key %s): %d failed: %s\n", e.Batch, e.FirstKey, e.Failed, e.Error)
+	}
+}
@@ -0,0 +1,147 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/chaos"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/cobra"
+)
+
+// addChaosFlags registers the dirty-data flags of generate_customers and generate_events.
+func addChaosFlags(cmd *cobra.Command) {
+	cmd.Flags().Float64("chaos", 0, "Percentage of documents (0-100) to write as malformed variants")
+	cmd.Flags().String("chaos-variants", "all", "Comma-separated malformed variants to inject (null_identifier, missing_identifier, numeric_phone, emoji, oversized_string, huge_array, duplicate_id)")
+	cmd.Flags().Bool("chaos-report", true, "With --chaos, print the indexing and search report once the documents are written")
+}
+
+// chaosFromFlags returns the chaos profile selected by --chaos for documents of indexName, and the run
+// recording it, or nil when --chaos is not set. It exits on invalid values.
+func chaosFromFlags(cmd *cobra.Command, client *redis.Client, generator *faker.Generator, indexName string, idField string, count int, idOf func(j int) string) (*faker.Chaos, *chaos.Run) {
+	percent, _ := cmd.Flags().GetFloat64("chaos")
+	if percent == 0 {
+		return nil, nil
+	}
+	list, _ := cmd.Flags().GetString("chaos-variants")
+	variants, err := faker.ParseChaosVariants(list)
+	if err != nil {
+		fmt.Println("Invalid options:", err)
+		os.Exit(1)
+	}
+	profile, err := generator.Chaos(faker.ChaosProfile{Percent: percent, Variants: variants}, schema.Get().MustIndex(indexName), idField, idOf)
+	if err != nil {
+		fmt.Println("Invalid options:", err)
+		os.Exit(1)
+	}
+	run, err := chaos.NewRun(client, profile, count)
+	if err != nil {
+		fmt.Println("Error reading index info:", err)
+		os.Exit(1)
+	}
+	return profile, run
+}
+
+// finishChaos stores the chaos run and, unless --chaos-report=false, prints its report.
+func finishChaos(cmd *cobra.Command, client *redis.Client, profile *faker.Chaos, run *chaos.Run) {
+	if profile == nil {
+		return
+	}
+	if err := run.Finish(context.Background(), client, profile); err != nil {
+		fmt.Println("Error saving chaos run:", err)
+		os.Exit(1)
+	}
+	if show, _ := cmd.Flags().GetBool("chaos-report"); !show {
+		fmt.Printf("Chaos run saved to %s.\n", chaos.RunKey(run.Prefix))
+		return
+	}
+	report, err := chaos.BuildReport(context.Background(), client, profile.Index(), *run)
+	if err != nil {
+		fmt.Println("Error building chaos report:", err)
+		os.Exit(1)
+	}
+	printChaosReport(report)
+}
+
+// printChaosReport prints a chaos report as a table, one line per variant.
+func printChaosReport(report chaos.Report) {
+	fmt.Printf("Chaos report for %s: %d of %d documents malformed, hash_indexing_failures %d -> %d (+%d).\n",
+		report.Index, report.Injected, report.Documents, report.IndexingFailuresBefore, report.IndexingFailuresAfter, report.NewIndexingFailures)
+	if report.LastIndexingError != "" {
+		fmt.Printf("Last indexing error: %s (key %s", report.LastIndexingError, report.LastIndexingErrorKey)
+		if report.LastIndexingErrorVariant != "" {
+			fmt.Printf(", %s", report.LastIndexingErrorVariant)
+		}
+		fmt.Println(")")
+	}
+	fmt.Printf("%-20s %9s %8s %8s %12s %11s %14s\n", "VARIANT", "INJECTED", "SAMPLED", "INDEXED", "NOT INDEXED", "SEARCHABLE", "SEARCH ERRORS")
+	for _, v := range report.Variants {
+		fmt.Printf("%-20s %9d %8d %8d %12d %11d %14d\n", v.Variant, v.Injected, v.Sampled, v.Indexed, v.NotIndexed, v.Searchable, v.SearchErrors)
+	}
+	for _, v := range report.Variants {
+		for _, s := range v.Samples {
+			if s.Error != "" {
+				fmt.Printf("  %s %s: %s\n", v.Variant, s.Key, s.Error)
+				break
+			}
+		}
+	}
+}
+
+var ChaosReportCmd = &cobra.Command{
+	Use:   "chaos_report [customers|events]",
+	Short: "Report how the documents of the latest --chaos run were indexed and behave in search",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var indexName string
+		switch args[0] {
+		case "customers":
+			indexName = schema.CustomerIndex
+		case "events":
+			indexName = schema.EventIndex
+		default:
+			fmt.Println("Invalid options: dataset must be customers or events")
+			os.Exit(1)
+		}
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+		idx := schema.Get().MustIndex(indexName)
+		run, err := chaos.LoadRun(context.Background(), client, idx.Prefix)
+		if errors.Is(err, chaos.ErrNoRun) {
+			fmt.Printf("No chaos run recorded for %s; generate with --chaos first.\n", args[0])
+			os.Exit(1)
+		}
+		if err != nil {
+			fmt.Println("Error loading chaos run:", err)
+			os.Exit(1)
+		}
+		report, err := chaos.BuildReport(context.Background(), client, idx, run)
+		if err != nil {
+			fmt.Println("Error building chaos report:", err)
+			os.Exit(1)
+		}
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			out, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
+		printChaosReport(report)
+	},
+}
+
+func init() {
+	ChaosReportCmd.Flags().Bool("json", false, "Print the full report, including every sample, as JSON")
+}
@@ -1,15 +1,62 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"github.com/spf13/cobra"
+	"strings"
+
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/spf13/cobra"
 )
 
 var CreateIndexesCmd = &cobra.Command{
 	Use:   "create_indexes",
-	Short: "Create RediSearch indexes in Redis",
+	Short: "Build new versions of the RediSearch indexes and swap their aliases once indexed",
+	Run: func(cmd *cobra.Command, args []string) {
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+		failed := false
+		for _, idx := range schema.Get().Indexes {
+			physical, err := redisutil.StartReindex(client, &idx)
+			if err != nil {
+				fmt.Printf("Error creating %s: %v\n", idx.Name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("Created %s on %s, indexing...\n", physical, strings.Join(idx.Aliases(), ", "))
+			last := -10
+			err = redisutil.FinishReindex(context.Background(), client, idx.Name, physical, func(percent float64) {
+				if p := int(percent * 100); p/10 != last/10 {
+					last = p
+					fmt.Printf("  %s: %d%% indexed\n", physical, p)
+				}
+			})
+			if err != nil {
+				fmt.Printf("Error reindexing %s: %v\n", idx.Name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s now points at %s\n", idx.Name, physical)
+		}
+		if failed {
+			os.Exit(1)
+		}
+	},
+}
+
+var RollbackIndexesCmd = &cobra.Command{
+	Use:   "rollback_indexes [index ...]",
+	Short: "Point index aliases back at their previous version",
+	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		redisURL := os.Getenv("REDIS_URL")
 		if redisURL == "" {
@@ -20,17 +67,24 @@ var CreateIndexesCmd = &cobra.Command{
 			fmt.Println("Error creating Redis client:", err)
 			os.Exit(1)
 		}
-		err1 := redisutil.CreateCustomerIndex(client)
-		err2 := redisutil.CreateEventIndex(client)
-		if err1 != nil {
-			fmt.Println("Error creating customer index:", err1)
-		} else {
-			fmt.Println("Created customerIdx on email, phone, visitor_id")
-		}
-		if err2 != nil {
-			fmt.Println("Error creating event index:", err2)
-		} else {
-			fmt.Println("Created eventIdx on all identifiers")
+		names := args
+		if len(names) == 0 {
+			for _, idx := range schema.Get().Indexes {
+				names = append(names, idx.Name)
+			}
+		}
+		failed := false
+		for _, name := range names {
+			target, err := redisutil.RollbackIndex(client, name)
+			if err != nil {
+				fmt.Printf("Error rolling back %s: %v\n", name, err)
+				failed = true
+				continue
+			}
+			fmt.Printf("%s now points at %s\n", name, target)
+		}
+		if failed {
+			os.Exit(1)
 		}
 	},
 }
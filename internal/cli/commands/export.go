@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+// exportState is the checkpoint file of an export to a file: where to resume, what was being exported
+// (so a checkpoint is not applied to a different export) and the size of the output up to the checkpoint.
+type exportState struct {
+	redisutil.ExportCheckpoint
+	Prefix string `json:"prefix"`
+	Index  string `json:"index,omitempty"`
+	Query  string `json:"query,omitempty"`
+	Gzip   bool   `json:"gzip"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// exportWriter buffers (and optionally compresses) the export output. sync makes everything written so
+// far complete on the output: with gzip it ends the current gzip member, so an output truncated to a
+// synced size is still a valid (multi-member) gzip stream.
+type exportWriter struct {
+	out *countingWriter
+	buf *bufio.Writer
+	gz  *gzip.Writer
+}
+
+func newExportWriter(out io.Writer, size int64, compress bool) *exportWriter {
+	w := &exportWriter{out: &countingWriter{w: out, n: size}}
+	w.buf = bufio.NewWriterSize(w.out, 1<<20)
+	if compress {
+		w.gz = gzip.NewWriter(w.buf)
+	}
+	return w
+}
+
+func (w *exportWriter) Write(p []byte) (int, error) {
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *exportWriter) sync() error {
+	if w.gz != nil {
+		if err := w.gz.Close(); err != nil {
+			return err
+		}
+		w.gz.Reset(w.buf)
+	}
+	return w.buf.Flush()
+}
+
+// readCheckpoint loads a checkpoint file into v. It returns false when the file does not exist.
+func readCheckpoint(path string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	return true, nil
+}
+
+// writeCheckpoint replaces a checkpoint file with v, atomically so an interrupted run leaves either the
+// old or the new checkpoint.
+func writeCheckpoint(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+var ExportCmd = &cobra.Command{
+	Use:   "export [customers|events]",
+	Short: "Export whole documents with their keys as NDJSON, by key prefix and/or query (resumable)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var state exportState
+		var idx *schema.Index
+		if len(args) == 1 {
+			switch args[0] {
+			case "customers":
+				idx = schema.Get().MustIndex(schema.CustomerIndex)
+			case "events":
+				idx = schema.Get().MustIndex(schema.EventIndex)
+			default:
+				fmt.Println("Invalid options: dataset must be customers or events")
+				os.Exit(1)
+			}
+			state.Prefix = idx.Prefix
+		}
+		if cmd.Flags().Changed("prefix") {
+			state.Prefix, _ = cmd.Flags().GetString("prefix")
+		}
+		if q, _ := cmd.Flags().GetString("q"); q != "" {
+			if idx == nil {
+				fmt.Println("Invalid options: --q needs a dataset (customers or events)")
+				os.Exit(1)
+			}
+			compiled, err := query.BuildWithQuery(idx, nil, q)
+			if err != nil {
+				fmt.Println("Invalid options:", err)
+				os.Exit(1)
+			}
+			state.Index, state.Query = idx.Name, compiled
+		}
+		if state.Prefix == "" && state.Index == "" {
+			fmt.Println("Invalid options: give a dataset (customers or events) or --prefix")
+			os.Exit(1)
+		}
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		output, _ := cmd.Flags().GetString("output")
+		state.Gzip, _ = cmd.Flags().GetBool("gzip")
+		state.Gzip = state.Gzip || strings.HasSuffix(output, ".gz")
+		if state.Gzip && output != "-" && !strings.HasSuffix(output, ".gz") {
+			output += ".gz"
+		}
+
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+
+		// Messages go to stderr when the documents go to stdout.
+		log := io.Writer(os.Stdout)
+		out := os.Stdout
+		checkpoint := ""
+		if output == "-" {
+			log = os.Stderr
+		} else {
+			checkpoint, _ = cmd.Flags().GetString("checkpoint")
+			if checkpoint == "" {
+				checkpoint = output + ".export.checkpoint"
+			}
+			var saved exportState
+			found, err := readCheckpoint(checkpoint, &saved)
+			if err != nil {
+				fmt.Println("Error reading checkpoint:", err)
+				os.Exit(1)
+			}
+			if found && (saved.Prefix != state.Prefix || saved.Index != state.Index || saved.Query != state.Query || saved.Gzip != state.Gzip) {
+				fmt.Printf("Error: checkpoint %s belongs to a different export; delete it to start over\n", checkpoint)
+				os.Exit(1)
+			}
+			if found {
+				state = saved
+				out, err = os.OpenFile(output, os.O_RDWR|os.O_CREATE, 0o644)
+				if err == nil {
+					err = out.Truncate(state.Bytes)
+				}
+				if err == nil {
+					_, err = out.Seek(state.Bytes, io.SeekStart)
+				}
+				fmt.Printf("Resuming export to %s after %d documents\n", output, state.Exported)
+			} else {
+				out, err = os.Create(output)
+			}
+			if err != nil {
+				fmt.Println("Error opening output:", err)
+				os.Exit(1)
+			}
+			defer out.Close()
+		}
+
+		w := newExportWriter(out, state.Bytes, state.Gzip)
+		start, resumed := time.Now(), state.Exported
+		next := state.Exported + exportProgressEvery
+		cp, err := redisutil.ExportNDJSON(context.Background(), client, w, redisutil.NDJSONExportOptions{
+			Prefix:    state.Prefix,
+			Index:     state.Index,
+			Query:     state.Query,
+			BatchSize: batchSize,
+			Resume:    state.ExportCheckpoint,
+			OnBatch: func(cp redisutil.ExportCheckpoint) error {
+				if err := w.sync(); err != nil {
+					return err
+				}
+				if cp.Exported >= next && !cp.Done {
+					fmt.Fprintf(log, "Exported %d documents...\n", cp.Exported)
+					next = cp.Exported + exportProgressEvery
+				}
+				if checkpoint == "" {
+					return nil
+				}
+				state.ExportCheckpoint, state.Bytes = cp, w.out.n
+				return writeCheckpoint(checkpoint, state)
+			},
+		})
+		if err != nil {
+			fmt.Fprintln(log, "Error exporting:", err)
+			if checkpoint != "" {
+				fmt.Fprintf(log, "Run the same command again to resume after %d documents.\n", state.Exported)
+			}
+			os.Exit(1)
+		}
+		if checkpoint != "" {
+			if err := os.Remove(checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Println("Error removing checkpoint:", err)
+			}
+		}
+		elapsed := time.Since(start)
+		fmt.Fprintf(log, "Done. Exported %d documents to %s in %s (%.0f docs/s, %.1f MB).\n",
+			cp.Exported, output, elapsed.Round(time.Millisecond), float64(cp.Exported-resumed)/elapsed.Seconds(), float64(w.out.n)/(1<<20))
+	},
+}
+
+// exportProgressEvery is how many documents pass between export progress lines.
+const exportProgressEvery = 100000
+
+func init() {
+	ExportCmd.Flags().String("output", "-", "NDJSON file to write, or - for stdout")
+	ExportCmd.Flags().String("prefix", "", "Export the JSON documents whose key starts with this prefix (default: the dataset's key prefix)")
+	ExportCmd.Flags().String("q", "", "Only export the documents of the dataset matching this boolean query, e.g. 'event_type:purchase'")
+	ExportCmd.Flags().Bool("gzip", false, "Gzip-compress the output (implied by a .gz file name)")
+	ExportCmd.Flags().Int("batch-size", redisutil.DefaultBulkBatchSize, "Documents per SCAN/cursor page and JSON.MGET")
+	ExportCmd.Flags().String("checkpoint", "", "Checkpoint file for resuming an interrupted export (default: <output>.export.checkpoint)")
+}
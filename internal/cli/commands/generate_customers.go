@@ -1,14 +1,14 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/spf13/cobra"
 	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
-	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
 var GenerateCustomersCmd = &cobra.Command{
@@ -31,17 +31,49 @@ var GenerateCustomersCmd = &cobra.Command{
 			fmt.Println("Error creating Redis client:", err)
 			return
 		}
-		for i := 0; i < count; i++ {
-			customer := faker.RandomCustomer()
-			key := "customer:" + strconv.Itoa(i)
-			err := redisutil.StoreJSON(client, key, customer)
+		opts := bulkOptionsFromFlags(cmd, "customers", count)
+		keys := keyAllocatorFromFlags(cmd, client, "customer:", count)
+		generator := generatorFromFlags(cmd)
+		if export, ok := exportOptionsFromFlags(cmd, opts); ok {
+			stats, files, err := redisutil.ExportRESP(context.Background(), count, export, func(i int) (string, interface{}, error) {
+				doc := generator.Customer(i)
+				key, err := keys.Key(i, doc.CustomerID)
+				return key, doc, err
+			})
+			stats.Keys = append(stats.Keys, keys.Range())
+			printExportStats(stats, files, "customers")
 			if err != nil {
-				fmt.Printf("Error storing customer %d: %v\n", i, err)
+				fmt.Println("Error writing RESP file:", err)
+				os.Exit(1)
 			}
-			if (i+1)%100 == 0 {
-				fmt.Printf("Stored %d customers...\n", i+1)
+			if stats.Failed > 0 {
+				os.Exit(1)
 			}
+			return
+		}
+		chaos, run := chaosFromFlags(cmd, client, generator, schema.CustomerIndex, "customerId", count, func(j int) string {
+			return generator.Customer(j).CustomerID
+		})
+		stats, err := redisutil.BulkGenerate(context.Background(), client, count, opts, func(i int) (string, interface{}, error) {
+			doc := generator.Customer(i)
+			key, err := keys.Key(i, doc.CustomerID)
+			if err != nil {
+				return key, nil, err
+			}
+			out, err := chaos.Break(i, key, doc)
+			return key, out, err
+		})
+		stats.Keys = append(stats.Keys, keys.Range())
+		printBulkStats(stats, "customers")
+		finishChaos(cmd, client, chaos, run)
+		if err != nil || stats.Failed > 0 {
+			os.Exit(1)
 		}
-		fmt.Printf("Done. Stored %d customers in Redis.\n", count)
 	},
 }
+
+func init() {
+	addBulkFlags(GenerateCustomersCmd)
+	addChaosFlags(GenerateCustomersCmd)
+	addExportFlags(GenerateCustomersCmd)
+}
@@ -1,14 +1,14 @@
 package commands
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"strconv"
 
 	"github.com/spf13/cobra"
 	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
-	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
 var GenerateEventsCmd = &cobra.Command{
@@ -31,17 +31,50 @@ var GenerateEventsCmd = &cobra.Command{
 			fmt.Println("Error creating Redis client:", err)
 			return
 		}
-		for i := 0; i < count; i++ {
-			event := faker.RandomEvent()
-			key := "event:" + strconv.Itoa(i)
-			err := redisutil.StoreJSON(client, key, event)
+		opts := bulkOptionsFromFlags(cmd, "events", count)
+		keys := keyAllocatorFromFlags(cmd, client, "event:", count)
+		generator := generatorFromFlags(cmd)
+		if export, ok := exportOptionsFromFlags(cmd, opts); ok {
+			stats, files, err := redisutil.ExportRESP(context.Background(), count, export, func(i int) (string, interface{}, error) {
+				doc := generator.Event(i)
+				key, err := keys.Key(i, doc.EventID)
+				return key, doc, err
+			})
+			stats.Keys = append(stats.Keys, keys.Range())
+			printExportStats(stats, files, "events")
 			if err != nil {
-				fmt.Printf("Error storing event %d: %v\n", i, err)
+				fmt.Println("Error writing RESP file:", err)
+				os.Exit(1)
 			}
-			if (i+1)%100 == 0 {
-				fmt.Printf("Stored %d events...\n", i+1)
+			if stats.Failed > 0 {
+				os.Exit(1)
 			}
+			return
+		}
+		chaos, run := chaosFromFlags(cmd, client, generator, schema.EventIndex, "event_id", count, func(j int) string {
+			return generator.Event(j).EventID
+		})
+		stats, err := redisutil.BulkGenerate(context.Background(), client, count, opts, func(i int) (string, interface{}, error) {
+			doc := generator.Event(i)
+			key, err := keys.Key(i, doc.EventID)
+			if err != nil {
+				return key, nil, err
+			}
+			out, err := chaos.Break(i, key, doc)
+			return key, out, err
+		})
+		stats.Keys = append(stats.Keys, keys.Range())
+		printBulkStats(stats, "events")
+		finishChaos(cmd, client, chaos, run)
+		if err != nil || stats.Failed > 0 {
+			os.Exit(1)
 		}
-		fmt.Printf("Done. Stored %d events in Redis.\n", count)
 	},
 }
+
+func init() {
+	addBulkFlags(GenerateEventsCmd)
+	addChaosFlags(GenerateEventsCmd)
+	addExportFlags(GenerateEventsCmd)
+	addEventMixFlag(GenerateEventsCmd)
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/spf13/cobra"
+)
+
+var GenerateLinkedCmd = &cobra.Command{
+	Use:   "generate_linked [customers] [events]",
+	Short: "Generate customers and events that belong to them (shared visitor, email, phone, call and chat IDs)",
+	Long: `Generate a population of customers, then events whose identifiers are taken from those customers so
+events can be joined to customers. --distribution sets how many events each customer gets:
+  uniform   every customer gets about the same number
+  zipf      customer j gets a share proportional to 1/(j+1)^s (--exponent s, default 1)
+  powerlaw  each customer's share is drawn from a Pareto distribution (--exponent alpha, default 2;
+            lower values give a heavier tail)`,
+	Args: cobra.MaximumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		customers, events := 1000, 10000
+		if len(args) > 0 {
+			if n, err := cliutil.ParseInt(args[0]); err == nil {
+				customers = n
+			}
+		}
+		if len(args) > 1 {
+			if n, err := cliutil.ParseInt(args[1]); err == nil {
+				events = n
+			}
+		}
+		distName, _ := cmd.Flags().GetString("distribution")
+		exponent, _ := cmd.Flags().GetFloat64("exponent")
+		dist, err := faker.ParseDistribution(distName)
+		if err != nil {
+			fmt.Println("Invalid options:", err)
+			os.Exit(1)
+		}
+		population, err := generatorFromFlags(cmd).Population(customers, dist, exponent)
+		if err != nil {
+			fmt.Println("Invalid options:", err)
+			os.Exit(1)
+		}
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			return
+		}
+
+		opts := bulkOptionsFromFlags(cmd, "customers", customers)
+		keys := keyAllocatorFromFlags(cmd, client, "customer:", customers)
+		stats, err := redisutil.BulkGenerate(context.Background(), client, customers, opts, func(i int) (string, interface{}, error) {
+			doc := population.Customer(i)
+			key, err := keys.Key(i, doc.CustomerID)
+			return key, doc, err
+		})
+		stats.Keys = append(stats.Keys, keys.Range())
+		printBulkStats(stats, "customers")
+		if err != nil || stats.Failed > 0 {
+			os.Exit(1)
+		}
+
+		opts = bulkOptionsFromFlags(cmd, "events", events)
+		keys = keyAllocatorFromFlags(cmd, client, "event:", events)
+		stats, err = redisutil.BulkGenerate(context.Background(), client, events, opts, func(i int) (string, interface{}, error) {
+			doc := population.Event(i)
+			key, err := keys.Key(i, doc.EventID)
+			return key, doc, err
+		})
+		stats.Keys = append(stats.Keys, keys.Range())
+		printBulkStats(stats, "events")
+		if err != nil || stats.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addBulkFlags(GenerateLinkedCmd)
+	GenerateLinkedCmd.Flags().String("distribution", string(faker.DistUniform), "Events per customer: uniform, zipf or powerlaw")
+	GenerateLinkedCmd.Flags().Float64("exponent", 0, "Zipf s or power-law alpha (default: 1 for zipf, 2 for powerlaw)")
+	addEventMixFlag(GenerateLinkedCmd)
+}
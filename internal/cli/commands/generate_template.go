@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/faker"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/spf13/cobra"
+)
+
+var GenerateCmd = &cobra.Command{
+	Use:   "generate --template file.yaml [count]",
+	Short: "Generate and store documents described by a YAML/JSON template",
+	Long: `Generate documents of any shape from a template file (see internal/faker/template.go for the format).
+Documents are stored under the template's prefix, or --prefix.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		count := 1000
+		if len(args) > 0 {
+			if n, err := cliutil.ParseInt(args[0]); err == nil {
+				count = n
+			}
+		}
+		path, _ := cmd.Flags().GetString("template")
+		tmpl, err := faker.LoadTemplate(path)
+		if err != nil {
+			fmt.Println("Error loading template:", err)
+			os.Exit(1)
+		}
+		prefix, _ := cmd.Flags().GetString("prefix")
+		if prefix == "" {
+			prefix = tmpl.Prefix
+		}
+		if prefix == "" {
+			fmt.Println("Invalid options: the template has no prefix or name; set --prefix")
+			os.Exit(1)
+		}
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			return
+		}
+		opts := bulkOptionsFromFlags(cmd, "documents", count)
+		keys := keyAllocatorFromFlags(cmd, client, prefix, count)
+		generator := generatorFromFlags(cmd)
+		stats, err := redisutil.BulkGenerate(context.Background(), client, count, opts, func(i int) (string, interface{}, error) {
+			doc, err := generator.Document(tmpl, i)
+			if err != nil {
+				return "", nil, err
+			}
+			key, err := keys.Key(i, tmpl.ID(doc))
+			return key, doc, err
+		})
+		stats.Keys = append(stats.Keys, keys.Range())
+		printBulkStats(stats, "documents")
+		if err != nil || stats.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	addBulkFlags(GenerateCmd)
+	GenerateCmd.Flags().String("template", "", "Template file (YAML or JSON)")
+	GenerateCmd.Flags().String("prefix", "", "Key prefix (default: the template's prefix, or its name followed by ':')")
+	GenerateCmd.MarkFlagRequired("template")
+}
@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/spf13/cobra"
+)
+
+// importState is the checkpoint file of an import: how many lines of the input are done.
+type importState struct {
+	Lines int64 `json:"lines"`
+}
+
+var ImportCmd = &cobra.Command{
+	Use:   "import [file|-]",
+	Short: "Import an NDJSON export (plain or gzip) into Redis with pipelined writes (resumable)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := args[0]
+		var opts redisutil.NDJSONImportOptions
+		opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.Workers, _ = cmd.Flags().GetInt("workers")
+		retries, _ := cmd.Flags().GetInt("max-retries")
+		opts.MaxRetries = &retries
+		opts.UseMSET, _ = cmd.Flags().GetBool("mset")
+		if err := opts.Validate(); err != nil {
+			fmt.Println("Invalid options:", err)
+			os.Exit(1)
+		}
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+
+		var in io.Reader = os.Stdin
+		checkpoint := ""
+		if path != "-" {
+			f, err := os.Open(path)
+			if err != nil {
+				fmt.Println("Error opening input:", err)
+				os.Exit(1)
+			}
+			defer f.Close()
+			in = f
+			checkpoint, _ = cmd.Flags().GetString("checkpoint")
+			if checkpoint == "" {
+				checkpoint = path + ".import.checkpoint"
+			}
+			var state importState
+			found, err := readCheckpoint(checkpoint, &state)
+			if err != nil {
+				fmt.Println("Error reading checkpoint:", err)
+				os.Exit(1)
+			}
+			if found {
+				opts.Skip = state.Lines
+				fmt.Printf("Resuming import of %s after line %d\n", path, state.Lines)
+			}
+		}
+
+		var mu sync.Mutex
+		next := int64(importProgressEvery)
+		opts.OnProgress = func(written, failed int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			if written+failed >= next {
+				fmt.Printf("Imported %d documents...\n", written)
+				next = written + failed + importProgressEvery
+			}
+		}
+		var checkpointErr error
+		if checkpoint != "" {
+			opts.OnCheckpoint = func(lines int64) {
+				if err := writeCheckpoint(checkpoint, importState{Lines: lines}); err != nil && checkpointErr == nil {
+					checkpointErr = err
+				}
+			}
+		}
+		stats, lines, err := redisutil.ImportNDJSON(context.Background(), client, in, opts)
+		printBulkStats(stats, "documents")
+		if checkpointErr != nil {
+			fmt.Println("Error writing checkpoint:", checkpointErr)
+		}
+		if err != nil {
+			fmt.Println("Error importing:", err)
+			if checkpoint != "" {
+				fmt.Printf("Run the same command again to resume after line %d.\n", lines)
+			}
+			os.Exit(1)
+		}
+		if checkpoint != "" {
+			if err := os.Remove(checkpoint); err != nil && !errors.Is(err, os.ErrNotExist) {
+				fmt.Println("Error removing checkpoint:", err)
+			}
+		}
+		if stats.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// importProgressEvery is how many documents pass between import progress lines.
+const importProgressEvery = 100000
+
+func init() {
+	ImportCmd.Flags().Int("batch-size", redisutil.DefaultBulkBatchSize, "Documents per pipelined round trip")
+	ImportCmd.Flags().Int("workers", 0, "Concurrent pipelines (default: number of CPUs)")
+	ImportCmd.Flags().Int("max-retries", redisutil.DefaultBulkMaxRetries, "Retries of a batch's transient failures, at most 10 (0 to turn retries off)")
+	ImportCmd.Flags().Bool("mset", false, "Write each batch with a single JSON.MSET (RedisJSON 2.6+) instead of pipelined JSON.SET")
+	ImportCmd.Flags().String("checkpoint", "", "Checkpoint file for resuming an interrupted import (default: <file>.import.checkpoint)")
+}
@@ -0,0 +1,73 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/spf13/cobra"
+)
+
+var IndexesCmd = &cobra.Command{
+	Use:   "indexes",
+	Short: "Inspect RediSearch indexes on the server",
+}
+
+var IndexesStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show live FT.INFO data for the schema indexes",
+	Run: func(cmd *cobra.Command, args []string) {
+		asJSON, _ := cmd.Flags().GetBool("json")
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+		indexes, err := redisutil.GetIndexesAndFields(client)
+		if err != nil {
+			fmt.Println("Error reading index info:", err)
+			os.Exit(1)
+		}
+		if asJSON {
+			out, _ := json.MarshalIndent(indexes, "", "  ")
+			fmt.Println(string(out))
+			return
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tINDEX\tDOCS\tINDEXED\tFAILURES\tMEMORY_MB\tFIELDS")
+		for _, idx := range indexes {
+			if !idx.Exists {
+				fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\tmissing\n", idx.Name)
+				continue
+			}
+			fields := make([]string, len(idx.Fields))
+			for i, f := range idx.Fields {
+				fields[i] = f.Alias + ":" + f.Type
+			}
+			state := fmt.Sprintf("%.0f%%", idx.PercentIndexed*100)
+			if idx.Indexing {
+				state += " (indexing)"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%.2f\t%s\n", idx.Name, idx.IndexName, idx.NumDocs, state,
+				idx.IndexingFailures, idx.Memory.TotalIndexMemorySzMB, strings.Join(fields, ", "))
+		}
+		w.Flush()
+		for _, idx := range indexes {
+			for _, d := range idx.SchemaDrift {
+				fmt.Printf("warning: %s: %s\n", idx.Name, d)
+			}
+		}
+	},
+}
+
+func init() {
+	IndexesStatusCmd.Flags().Bool("json", false, "Print the full index info as JSON")
+	IndexesCmd.AddCommand(IndexesStatusCmd)
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/spf13/cobra"
+)
+
+// addExportFlags registers the RESP file export flags of generate_customers and generate_events.
+func addExportFlags(cmd *cobra.Command) {
+	cmd.Flags().String("emit-resp", "", "Write the documents as RESP JSON.SET commands to this file instead of Redis (load with replay or redis-cli --pipe)")
+	cmd.Flags().Bool("gzip", false, "With --emit-resp, gzip-compress the output (implied by a .gz file name)")
+	cmd.Flags().Int("shards", 1, "With --emit-resp, split the output into this many files that can be replayed in parallel")
+}
+
+// exportOptionsFromFlags returns the --emit-resp options, or false when the documents go to Redis. It exits
+// on invalid combinations.
+func exportOptionsFromFlags(cmd *cobra.Command, opts redisutil.BulkOptions) (redisutil.ExportOptions, bool) {
+	path, _ := cmd.Flags().GetString("emit-resp")
+	if path == "" {
+		return redisutil.ExportOptions{}, false
+	}
+	if percent, _ := cmd.Flags().GetFloat64("chaos"); percent != 0 {
+		fmt.Println("Invalid options: --chaos reports on a live index and cannot be combined with --emit-resp")
+		os.Exit(1)
+	}
+	export := redisutil.ExportOptions{BulkOptions: opts, Path: path}
+	export.Gzip, _ = cmd.Flags().GetBool("gzip")
+	export.Shards, _ = cmd.Flags().GetInt("shards")
+	if export.Shards < 1 {
+		fmt.Println("Invalid options: shards must be at least 1")
+		os.Exit(1)
+	}
+	return export, true
+}
+
+// printExportStats prints the outcome of a RESP export.
+func printExportStats(stats redisutil.BulkStats, files []redisutil.ExportFile, what string) {
+	fmt.Printf("Done. Wrote %d %s to %d file(s) in %s (%.0f docs/s, %.1f MB of RESP, %d failed).\n",
+		stats.Written, what, len(files), stats.Elapsed.Round(time.Millisecond), stats.DocsPerSec, float64(stats.Bytes)/(1<<20), stats.Failed)
+	for _, keys := range stats.Keys {
+		fmt.Printf("Keys: %s\n", keys)
+	}
+	for _, f := range files {
+		fmt.Printf("  %s: %d documents\n", f.Path, f.Documents)
+	}
+	printBatchErrors(stats.Errors)
+}
+
+var ReplayCmd = &cobra.Command{
+	Use:   "replay [file ...]",
+	Short: "Stream RESP mass-insert files (from --emit-resp or redis-cli) into Redis with pipelining",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = "redis://localhost:6379/0"
+		}
+		client, err := redisutil.NewRedisClient(redisURL)
+		if err != nil {
+			fmt.Println("Error creating Redis client:", err)
+			os.Exit(1)
+		}
+		var opts redisutil.BulkOptions
+		opts.BatchSize, _ = cmd.Flags().GetInt("batch-size")
+		opts.Workers, _ = cmd.Flags().GetInt("workers")
+		retries, _ := cmd.Flags().GetInt("max-retries")
+		opts.MaxRetries = &retries
+		if err := opts.Validate(); err != nil {
+			fmt.Println("Invalid options:", err)
+			os.Exit(1)
+		}
+		var mu sync.Mutex
+		next := int64(replayProgressEvery)
+		opts.OnProgress = func(written, failed int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			if written+failed >= next {
+				fmt.Printf("Replayed %d commands...\n", written+failed)
+				next = written + failed + replayProgressEvery
+			}
+		}
+		stats, err := redisutil.ReplayRESP(context.Background(), client, args, opts)
+		fmt.Printf("Done. Replayed %d commands from %d file(s) in %s (%.0f commands/s, %d batches, %d retries, %d failed).\n",
+			stats.Written, len(args), stats.Elapsed.Round(time.Millisecond), stats.DocsPerSec, stats.Batches, stats.Retries, stats.Failed)
+		printBatchErrors(stats.Errors)
+		if err != nil {
+			fmt.Println("Error replaying:", err)
+			os.Exit(1)
+		}
+		if stats.Failed > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+// replayProgressEvery is how many commands pass between replay progress lines.
+const replayProgressEvery = 100000
+
+func init() {
+	ReplayCmd.Flags().Int("batch-size", redisutil.DefaultBulkBatchSize, "Commands per pipelined round trip")
+	ReplayCmd.Flags().Int("workers", 0, "Pipelines in flight (default: number of CPUs); use 1 to apply commands in file order")
+	ReplayCmd.Flags().Int("max-retries", redisutil.DefaultBulkMaxRetries, "Retries of a batch's transient failures, at most 10 (0 to turn retries off)")
+}
@@ -2,142 +2,274 @@ package commands
 
 import (
 	"context"
-	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"math"
 	"math/rand"
 	"os"
+	"slices"
 	"sort"
 	"strings"
+	"text/tabwriter"
 	"time"
 
-	"github.com/redis/go-redis/v9"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/sample"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 	"github.com/spf13/cobra"
 )
 
-// SampleToCSVCommand implements fast sampling of Redis documents to CSV
+// SampleToCSVCommand writes a random sample of customer or event documents to a CSV, NDJSON or Parquet
+// file. Keys are streamed into a reservoir (SCAN over the type's prefix, or the matches of --query), then
+// the sampled documents are fetched in batches of --batch-size, projecting only --columns.
+//
+// --stratify samples every value of a field separately (--allocation proportional or equal) and
+// --recency-half-life favours recent documents; both read their fields while the keys are streamed.
+// --miss-ratio mixes in rows of identifiers that match no document, flagged by a trailing miss column.
 var SampleToCSVCommand = &cobra.Command{
 	Use:   "sample_to_csv",
-	Short: "Extract a random sample of customer or event documents from Redis and write to a CSV file",
+	Short: "Extract a random sample of customer or event documents from Redis to a CSV, NDJSON or Parquet file",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		typeStr, _ := cmd.Flags().GetString("type")
 		percent, _ := cmd.Flags().GetInt("percent")
+		size, _ := cmd.Flags().GetInt("size")
 		output, _ := cmd.Flags().GetString("output")
 		redisURL, _ := cmd.Flags().GetString("redis")
-		if typeStr != "customer" && typeStr != "event" {
+		columnList, _ := cmd.Flags().GetString("columns")
+		queryStr, _ := cmd.Flags().GetString("query")
+		formatName, _ := cmd.Flags().GetString("format")
+		batchSize, _ := cmd.Flags().GetInt("batch-size")
+		stratifyField, _ := cmd.Flags().GetString("stratify")
+		allocName, _ := cmd.Flags().GetString("allocation")
+		halfLife, _ := cmd.Flags().GetDuration("recency-half-life")
+		recencyField, _ := cmd.Flags().GetString("recency-field")
+		missRatio, _ := cmd.Flags().GetFloat64("miss-ratio")
+		var idx *schema.Index
+		switch typeStr {
+		case "customer", "customers":
+			idx = schema.Get().MustIndex(schema.CustomerIndex)
+		case "event", "events":
+			idx = schema.Get().MustIndex(schema.EventIndex)
+		default:
 			return fmt.Errorf("--type must be 'customer' or 'event'")
 		}
-		if percent <= 0 || percent > 100 {
+		if size < 0 {
+			return fmt.Errorf("--size must not be negative")
+		}
+		if size == 0 && (percent <= 0 || percent > 100) {
 			return fmt.Errorf("--percent must be between 1 and 100")
 		}
 		if output == "" {
 			return fmt.Errorf("--output is required")
 		}
+		if batchSize < 1 || batchSize > redisutil.MaxBulkBatchSize {
+			return fmt.Errorf("--batch-size must be between 1 and %d", redisutil.MaxBulkBatchSize)
+		}
+		if columnList == "" {
+			columnList = sample.DefaultColumns(idx)
+		}
+		columns, err := sample.ParseColumns(columnList, idx)
+		if err != nil {
+			return fmt.Errorf("--columns: %v", err)
+		}
+		format, err := sample.ParseFormat(formatName, output)
+		if err != nil {
+			return fmt.Errorf("--format: %v", err)
+		}
+		var stratify, recency *sample.Column
+		if stratifyField != "" {
+			col, err := sample.ParseField(stratifyField, idx)
+			if err != nil {
+				return fmt.Errorf("--stratify: %v", err)
+			}
+			stratify = &col
+		}
+		alloc, err := sample.ParseAllocation(allocName)
+		if err != nil {
+			return fmt.Errorf("--allocation: %v", err)
+		}
+		if halfLife < 0 {
+			return fmt.Errorf("--recency-half-life must not be negative")
+		}
+		if halfLife > 0 {
+			if recencyField == "" {
+				recencyField = sample.DefaultTimeField(idx)
+			}
+			if recencyField == "" {
+				return fmt.Errorf("--recency-field is required for index %s", idx.Name)
+			}
+			col, err := sample.ParseField(recencyField, idx)
+			if err != nil {
+				return fmt.Errorf("--recency-field: %v", err)
+			}
+			recency = &col
+		}
+		if missRatio < 0 || missRatio >= 1 {
+			return fmt.Errorf("--miss-ratio must be at least 0 and less than 1")
+		}
+		outColumns := columns
+		if missRatio > 0 {
+			for _, col := range columns {
+				if col.Name == sample.MissColumn {
+					return fmt.Errorf("--columns: column %q is reserved by --miss-ratio", sample.MissColumn)
+				}
+			}
+			outColumns = append(columns[:len(columns):len(columns)], sample.Column{Name: sample.MissColumn})
+		}
+		seed := time.Now().UnixNano()
+		if cmd.Flags().Changed("seed") {
+			seed, _ = cmd.Flags().GetInt64("seed")
+		}
+		if redisURL == "" {
+			redisURL = os.Getenv("REDIS_URL")
+		}
 		if redisURL == "" {
 			redisURL = "redis://localhost:6379"
 		}
 
 		ctx := context.Background()
-		opt, err := redis.ParseURL(redisURL)
+		client, err := redisutil.NewRedisClient(redisURL)
 		if err != nil {
-			return fmt.Errorf("invalid redis url: %v", err)
+			return err
 		}
-		client := redis.NewClient(opt)
 		defer client.Close()
 
-		// 1. List all keys
-		pattern := fmt.Sprintf("%s:*", typeStr)
-		var cursor uint64
-		var allKeys []string
-		for {
-			keys, next, err := client.Scan(ctx, cursor, pattern, 1000).Result()
-			if err != nil {
-				return fmt.Errorf("failed to scan keys: %v", err)
+		// 1. Size the reservoir: --size, or --percent of the documents the index counts for the query
+		sel := redisutil.KeySelection{Prefix: idx.Prefix}
+		if queryStr != "" {
+			sel.Index, sel.Query = idx.Name, queryStr
+		}
+		if size == 0 {
+			q := queryStr
+			if q == "" {
+				q = "*"
 			}
-			allKeys = append(allKeys, keys...)
-			if next == 0 {
-				break
+			total, _, err := redisutil.SearchKeys(client, idx.Name, q, 0)
+			if err != nil {
+				return fmt.Errorf("failed to count documents (use --size to sample without the index): %v", err)
 			}
-			cursor = next
+			size = max(1, int(total*int64(percent)/100))
 		}
-		if len(allKeys) == 0 {
-			return fmt.Errorf("no keys found for pattern %s", pattern)
-		}
-
-		// 2. Randomly sample keys
-		sampleSize := len(allKeys) * percent / 100
-		if sampleSize < 1 {
-			sampleSize = 1
-		}
-		rand.Seed(time.Now().UnixNano())
-		rand.Shuffle(len(allKeys), func(i, j int) { allKeys[i], allKeys[j] = allKeys[j], allKeys[i] })
-		sampledKeys := allKeys[:sampleSize]
-		sort.Strings(sampledKeys)
 
-		// 3. Fetch documents in batches
-		pipe := client.Pipeline()
-		cmds := make([]*redis.Cmd, len(sampledKeys))
-		for i, key := range sampledKeys {
-			cmds[i] = pipe.Do(ctx, "JSON.GET", key, "$")
+		// 2. Stream the keys through the reservoir, or through the strata when the sample needs field values
+		rng := rand.New(rand.NewSource(seed))
+		var keys []string
+		var seen int64
+		var strata []sample.Stratum
+		if stratify == nil && recency == nil {
+			reservoir := sample.NewReservoir(size, rng)
+			err = redisutil.EachKeyPage(ctx, client, sel, batchSize, 0, func(page []string, _ uint64, _ bool) error {
+				for _, key := range page {
+					reservoir.Offer(key)
+				}
+				return nil
+			})
+			keys, seen = reservoir.Keys(), reservoir.Seen()
+		} else {
+			var paths []string
+			for _, col := range []*sample.Column{stratify, recency} {
+				if col != nil && !slices.Contains(paths, col.Path) {
+					paths = append(paths, col.Path)
+				}
+			}
+			st := sample.NewStrata(size, rng)
+			now := time.Now()
+			err = redisutil.EachKeyPage(ctx, client, sel, batchSize, 0, func(page []string, _ uint64, _ bool) error {
+				docs, err := redisutil.GetJSONPaths(ctx, client, page, paths)
+				if err != nil {
+					return err
+				}
+				for i, matches := range docs {
+					if matches == nil {
+						continue
+					}
+					value, logWeight := "", 0.0
+					if stratify != nil {
+						if m := matches[stratify.Path]; len(m) > 0 {
+							value, _ = sample.Text(m[0])
+						}
+					}
+					if recency != nil {
+						var t json.RawMessage
+						if m := matches[recency.Path]; len(m) > 0 {
+							t = m[0]
+						}
+						logWeight = sample.RecencyLogWeight(t, now, halfLife)
+					}
+					if err := st.Offer(value, page[i], logWeight); err != nil {
+						return fmt.Errorf("--stratify %s: %v", stratifyField, err)
+					}
+				}
+				return nil
+			})
+			strata = st.Sample(alloc)
+			for _, s := range strata {
+				keys = append(keys, s.Keys...)
+			}
+			sort.Strings(keys)
+			seen = st.Seen()
 		}
-		_, err = pipe.Exec(ctx)
 		if err != nil {
-			return fmt.Errorf("failed to fetch documents: %v", err)
+			return fmt.Errorf("failed to list keys: %v", err)
+		}
+		if seen == 0 {
+			return fmt.Errorf("no documents found for prefix %s", idx.Prefix)
 		}
 
-		// 4. Write CSV
+		// 3. Fetch the sampled documents in batches and write them
 		f, err := os.Create(output)
 		if err != nil {
-			return fmt.Errorf("failed to create output CSV: %v", err)
+			return fmt.Errorf("failed to create output file: %v", err)
 		}
 		defer f.Close()
-		w := csv.NewWriter(f)
-		defer w.Flush()
-
-		if typeStr == "customer" {
-			header := []string{"key", "email", "phone", "visitor_id"}
-			w.Write(header)
-		} else {
-			header := []string{"key", "visitor_id", "call_id", "chat_id", "external_id", "form2lead_id", "tickets_id"}
-			w.Write(header)
+		w, err := sample.NewWriter(format, f, outColumns)
+		if err != nil {
+			return err
 		}
-
-		for i, cmd := range cmds {
-			jsonStr, err := cmd.Text()
-			if err != nil || jsonStr == "" {
-				continue // skip missing
-			}
-			// Remove outer array if present
-			jsonStr = strings.TrimSpace(jsonStr)
-			if strings.HasPrefix(jsonStr, "[") && strings.HasSuffix(jsonStr, "]") {
-				jsonStr = strings.TrimPrefix(jsonStr, "[")
-				jsonStr = strings.TrimSuffix(jsonStr, "]")
+		written, missed := 0, 0
+		for start := 0; start < len(keys); start += batchSize {
+			rows, err := sample.Fetch(ctx, client, keys[start:min(start+batchSize, len(keys))], columns)
+			if err != nil {
+				return fmt.Errorf("failed to fetch documents: %v", err)
 			}
-			var record map[string]interface{}
-			if err := jsonUnmarshalCompat(jsonStr, &record); err != nil {
-				continue
+			for _, row := range rows {
+				if missRatio > 0 {
+					row = append(row, json.RawMessage("false"))
+				}
+				if err := w.Write(row); err != nil {
+					return fmt.Errorf("failed to write %s: %v", format, err)
+				}
+				written++
+				// Keep misses at missRatio of all rows so far: misses / (written + misses).
+				for due := int(math.Round(float64(written) * missRatio / (1 - missRatio))); missed < due; missed++ {
+					miss := sample.MissRow(row, rng)
+					miss[len(miss)-1] = json.RawMessage("true")
+					if err := w.Write(miss); err != nil {
+						return fmt.Errorf("failed to write %s: %v", format, err)
+					}
+				}
 			}
-			row := []string{sampledKeys[i]}
-			if typeStr == "customer" {
-				row = append(row,
-					getStringField(record, "primaryIdentifiers", "email"),
-					getStringField(record, "primaryIdentifiers", "phone"),
-					getStringField(record, "primaryIdentifiers", "cmec_visitor_id"),
-				)
-			} else {
-				row = append(row,
-					getStringField(record, "identifiers", "visitor_id"),
-					getStringField(record, "identifiers", "call_id"),
-					getStringField(record, "identifiers", "chat_id"),
-					getStringField(record, "identifiers", "external_id"),
-					getStringField(record, "identifiers", "lead_id"),
-					getStringField(record, "identifiers", "tickets_id"),
-				)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %v", format, err)
+		}
+		if missed > 0 {
+			fmt.Printf("Sampled %d records out of %d, plus %d misses. Output written to %s\n", written, seen, missed, output)
+		} else {
+			fmt.Printf("Sampled %d records out of %d. Output written to %s\n", written, seen, output)
+		}
+		if stratify != nil {
+			tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, strings.ToUpper(stratify.Name)+"\tDOCUMENTS\tSAMPLED")
+			for _, s := range strata {
+				value := s.Value
+				if value == "" {
+					value = "(missing)"
+				}
+				fmt.Fprintf(tw, "%s\t%d\t%d\n", value, s.Seen, len(s.Keys))
 			}
-			w.Write(row)
+			tw.Flush()
 		}
-
-		fmt.Printf("Sampled %d records out of %d. Output written to %s\n", sampleSize, len(allKeys), output)
 		return nil
 	},
 }
@@ -145,31 +277,20 @@ var SampleToCSVCommand = &cobra.Command{
 func init() {
 	SampleToCSVCommand.Flags().String("type", "", "Type of document: customer or event (required)")
 	SampleToCSVCommand.Flags().Int("percent", 5, "Percent of records to sample (default 5)")
-	SampleToCSVCommand.Flags().String("output", "", "Output CSV file (required)")
+	SampleToCSVCommand.Flags().Int("size", 0, "Number of records to sample (overrides --percent)")
+	SampleToCSVCommand.Flags().String("output", "", "Output file (required)")
+	SampleToCSVCommand.Flags().String("format", "", "Output format: csv, ndjson or parquet (default: from the --output extension, else csv)")
+	SampleToCSVCommand.Flags().String("columns", "", "Comma-separated columns after the key: field aliases (email), JSONPaths ($.personalData.name) or name=field (default: the identifiers the k6 test uses)")
+	SampleToCSVCommand.Flags().String("query", "", "RediSearch query selecting the documents to sample from, e.g. '@event_type:{purchase}'")
+	SampleToCSVCommand.Flags().Int("batch-size", redisutil.DefaultBulkBatchSize, "Keys per SCAN/cursor page and documents per pipelined fetch")
+	SampleToCSVCommand.Flags().String("stratify", "", "Sample every value of this field separately: a field alias (event_type) or JSONPath ($.visitor_data.device_info.device_type)")
+	SampleToCSVCommand.Flags().String("allocation", string(sample.AllocProportional), "How --stratify shares the sample: proportional (to each value's documents) or equal (same count per value)")
+	SampleToCSVCommand.Flags().Duration("recency-half-life", 0, "Favour recent documents: one this much older is half as likely to be sampled, e.g. 24h (default: uniform)")
+	SampleToCSVCommand.Flags().String("recency-field", "", "Time field for --recency-half-life, Unix seconds or RFC 3339 (default: timestamp for events, $.updatedAt for customers)")
+	SampleToCSVCommand.Flags().Float64("miss-ratio", 0, "Fraction of output rows with identifiers that match no document, flagged by a miss column (0 to <1)")
+	SampleToCSVCommand.Flags().Int64("seed", 0, "Seed for a reproducible sample (default: random)")
 	SampleToCSVCommand.Flags().String("redis", "", "Redis connection URL (optional)")
 
 	_ = SampleToCSVCommand.MarkFlagRequired("type")
 	_ = SampleToCSVCommand.MarkFlagRequired("output")
 }
-
-// Helper to unmarshal JSON using encoding/json or a fallback for compatibility
-func jsonUnmarshalCompat(data string, v interface{}) error {
-	return json.Unmarshal([]byte(data), v)
-}
-
-// Helper to extract nested string fields safely
-func getStringField(m map[string]interface{}, parent, field string) string {
-	if m == nil {
-		return ""
-	}
-	p, ok := m[parent].(map[string]interface{})
-	if !ok {
-		return ""
-	}
-	val, ok := p[field]
-	if !ok {
-		return ""
-	}
-	s, _ := val.(string)
-	return s
-}
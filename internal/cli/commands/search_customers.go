@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"github.com/spf13/cobra"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
-	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
 
@@ -16,13 +15,8 @@ var SearchCustomersCmd = &cobra.Command{
 	Short: "Search customers in Redis",
 	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		identifiers := map[string]string{}
-		for _, arg := range args {
-			parts := strings.SplitN(arg, "=", 2)
-			if len(parts) == 2 {
-				identifiers[parts[0]] = parts[1]
-			}
-		}
+		idx := schema.Get().MustIndex(schema.CustomerIndex)
+		searchQuery, opts := buildSearch(cmd, idx, args)
 		redisURL := os.Getenv("REDIS_URL")
 		if redisURL == "" {
 			redisURL = "redis://localhost:6379/0"
@@ -32,13 +26,19 @@ var SearchCustomersCmd = &cobra.Command{
 			fmt.Println("Error creating Redis client:", err)
 			os.Exit(1)
 		}
-		query := cliutil.BuildRediSearchQuery(identifiers)
-		results, err := redisutil.SearchFTS(client, "customerIdx", query)
+		page, err := redisutil.SearchFTSWithOptions(client, idx.Name, searchQuery, opts)
 		if err != nil {
 			fmt.Println("RediSearch error:", err)
 			os.Exit(1)
 		}
-		out, _ := json.MarshalIndent(results, "", "  ")
+		out, _ := json.MarshalIndent(page.Docs, "", "  ")
 		fmt.Println(string(out))
 	},
 }
+
+func init() {
+	SearchCustomersCmd.AddCommand(newExplainCmd(schema.CustomerIndex), newProfileCmd(schema.CustomerIndex))
+	SearchCustomersCmd.PersistentFlags().String("q", "", "Boolean query, e.g. 'email:foo@x.com OR phone:555* -deleted:1'")
+	SearchCustomersCmd.PersistentFlags().String("fields", "", "Comma-separated fields/JSONPaths to return instead of the whole document (e.g. customerId,primaryIdentifiers)")
+	SearchCustomersCmd.PersistentFlags().Int("limit", 10, "Max results")
+}
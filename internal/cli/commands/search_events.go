@@ -4,10 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"strings"
 	"github.com/spf13/cobra"
 	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
-	"github.com/jricardooliveira/redis-document-data-search/internal/cliutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
 )
 
 
@@ -16,13 +15,8 @@ var SearchEventsCmd = &cobra.Command{
 	Short: "Search events in Redis",
 	Args:  cobra.ArbitraryArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		identifiers := map[string]string{}
-		for _, arg := range args {
-			parts := strings.SplitN(arg, "=", 2)
-			if len(parts) == 2 {
-				identifiers[parts[0]] = parts[1]
-			}
-		}
+		idx := schema.Get().MustIndex(schema.EventIndex)
+		searchQuery, opts := buildSearch(cmd, idx, args)
 		redisURL := os.Getenv("REDIS_URL")
 		if redisURL == "" {
 			redisURL = "redis://localhost:6379/0"
@@ -32,13 +26,23 @@ var SearchEventsCmd = &cobra.Command{
 			fmt.Println("Error creating Redis client:", err)
 			os.Exit(1)
 		}
-		query := cliutil.BuildRediSearchQuery(identifiers)
-		results, err := redisutil.SearchFTS(client, "eventIdx", query)
+		page, err := redisutil.SearchFTSWithOptions(client, idx.Name, searchQuery, opts)
 		if err != nil {
 			fmt.Println("RediSearch error:", err)
 			os.Exit(1)
 		}
-		out, _ := json.MarshalIndent(results, "", "  ")
+		out, _ := json.MarshalIndent(page.Docs, "", "  ")
 		fmt.Println(string(out))
 	},
 }
+
+func init() {
+	SearchEventsCmd.AddCommand(newExplainCmd(schema.EventIndex), newProfileCmd(schema.EventIndex))
+	SearchEventsCmd.PersistentFlags().String("q", "", "Boolean query, e.g. '(visitor_id:abc OR call_id:call_6z2kl) -event_type:page_view'")
+	SearchEventsCmd.PersistentFlags().String("from", "", "Only events at or after this time (RFC3339, Unix seconds or e.g. -24h)")
+	SearchEventsCmd.PersistentFlags().String("to", "", "Only events at or before this time (RFC3339, Unix seconds or e.g. -1h)")
+	SearchEventsCmd.PersistentFlags().String("sort-by", "", "Sortable field to order results by (e.g. timestamp)")
+	SearchEventsCmd.PersistentFlags().String("sort-dir", "asc", "Sort direction: asc or desc")
+	SearchEventsCmd.PersistentFlags().Int("limit", 10, "Max results")
+	SearchEventsCmd.PersistentFlags().String("fields", "", "Comma-separated fields/JSONPaths to return instead of the whole document (e.g. event_id,identifiers)")
+}
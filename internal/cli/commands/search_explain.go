@@ -0,0 +1,127 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/query"
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/spf13/cobra"
+)
+
+// buildSearch turns key=value arguments and the search flags (--q, --limit, --fields and, where the
+// command defines them, --from/--to and --sort-by/--sort-dir) into a query and search options.
+// It exits on invalid input.
+func buildSearch(cmd *cobra.Command, idx *schema.Index, args []string) (string, redisutil.SearchOptions) {
+	identifiers := map[string]string{}
+	for _, arg := range args {
+		parts := strings.SplitN(arg, "=", 2)
+		if len(parts) == 2 {
+			identifiers[parts[0]] = parts[1]
+		}
+	}
+	q, _ := cmd.Flags().GetString("q")
+	searchQuery, err := query.BuildWithQuery(idx, identifiers, q)
+	if err != nil {
+		fmt.Println("Invalid search:", err)
+		os.Exit(1)
+	}
+	var opts redisutil.SearchOptions
+	if cmd.Flags().Lookup("from") != nil {
+		from, _ := cmd.Flags().GetString("from")
+		to, _ := cmd.Flags().GetString("to")
+		if from != "" || to != "" {
+			min, max, err := schema.TimeRangeBounds(from, to, time.Now())
+			if err != nil {
+				fmt.Println("Invalid search:", err)
+				os.Exit(1)
+			}
+			searchQuery = query.Intersect(searchQuery, query.NumericRange(schema.EventTimeField, min, max))
+		}
+	}
+	if cmd.Flags().Lookup("sort-by") != nil {
+		opts.SortBy, _ = cmd.Flags().GetString("sort-by")
+		sortDir, _ := cmd.Flags().GetString("sort-dir")
+		opts.SortDesc, err = idx.ValidateSort(opts.SortBy, sortDir)
+		if err != nil {
+			fmt.Println("Invalid search:", err)
+			os.Exit(1)
+		}
+	}
+	fieldsFlag, _ := cmd.Flags().GetString("fields")
+	opts.Fields, err = redisutil.ParseFieldPaths(fieldsFlag)
+	if err != nil {
+		fmt.Println("Invalid search:", err)
+		os.Exit(1)
+	}
+	opts.Limit, _ = cmd.Flags().GetInt("limit")
+	return searchQuery, opts
+}
+
+// newExplainCmd returns the "explain" subcommand of a search command: it prints the FT.EXPLAIN plan of
+// the query the search would run, as JSON.
+func newExplainCmd(indexName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain [key=value ...]",
+		Short: "Show the FT.EXPLAIN execution plan of a search",
+		Args:  cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			idx := schema.Get().MustIndex(indexName)
+			searchQuery, _ := buildSearch(cmd, idx, args)
+			redisURL := os.Getenv("REDIS_URL")
+			if redisURL == "" {
+				redisURL = "redis://localhost:6379/0"
+			}
+			client, err := redisutil.NewRedisClient(redisURL)
+			if err != nil {
+				fmt.Println("Error creating Redis client:", err)
+				os.Exit(1)
+			}
+			plan, raw, err := redisutil.ExplainSearch(client, idx.Name, searchQuery)
+			if err != nil {
+				fmt.Println("RediSearch error:", err)
+				os.Exit(1)
+			}
+			out, _ := json.MarshalIndent(map[string]interface{}{
+				"index": idx.Name, "query": searchQuery, "plan": plan, "explain": raw,
+			}, "", "  ")
+			fmt.Println(string(out))
+		},
+	}
+}
+
+// newProfileCmd returns the "profile" subcommand of a search command: it runs the search under
+// FT.PROFILE and prints the iterator tree, counters and timings as JSON.
+func newProfileCmd(indexName string) *cobra.Command {
+	return &cobra.Command{
+		Use:   "profile [key=value ...]",
+		Short: "Run a search under FT.PROFILE and show iterator counts and timings",
+		Args:  cobra.ArbitraryArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			idx := schema.Get().MustIndex(indexName)
+			searchQuery, opts := buildSearch(cmd, idx, args)
+			redisURL := os.Getenv("REDIS_URL")
+			if redisURL == "" {
+				redisURL = "redis://localhost:6379/0"
+			}
+			client, err := redisutil.NewRedisClient(redisURL)
+			if err != nil {
+				fmt.Println("Error creating Redis client:", err)
+				os.Exit(1)
+			}
+			profile, err := redisutil.ProfileSearch(client, idx.Name, searchQuery, opts)
+			if err != nil {
+				fmt.Println("RediSearch error:", err)
+				os.Exit(1)
+			}
+			out, _ := json.MarshalIndent(map[string]interface{}{
+				"index": idx.Name, "query": searchQuery, "total": profile.Total, "profile": profile.Profile,
+			}, "", "  ")
+			fmt.Println(string(out))
+		},
+	}
+}
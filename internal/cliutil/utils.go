@@ -2,7 +2,6 @@ package cliutil
 
 import (
 	"fmt"
-	"strings"
 )
 
 func ParseInt(s string) (int, error) {
@@ -10,11 +9,3 @@ func ParseInt(s string) (int, error) {
 	_, err := fmt.Sscanf(s, "%d", &n)
 	return n, err
 }
-
-func BuildRediSearchQuery(identifiers map[string]string) string {
-	var parts []string
-	for k, v := range identifiers {
-		parts = append(parts, fmt.Sprintf("@%s:%s", k, v))
-	}
-	return strings.Join(parts, " ")
-}
@@ -0,0 +1,384 @@
+package faker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// Dirty data.
+//
+// Chaos turns a share of generated documents into malformed variants of themselves, to test how
+// ingestion, indexing and search cope with the documents real systems receive. The fields it breaks are
+// the indexed identifiers of the document's schema index; the document ID field is only touched by
+// ChaosDuplicateID. Which documents are broken, and how, depends only on the generator's seed and the
+// document index, like the documents themselves.
+
+// ChaosVariant is one kind of malformed document.
+type ChaosVariant string
+
+const (
+	// ChaosNullIdentifier sets an identifier to null.
+	ChaosNullIdentifier ChaosVariant = "null_identifier"
+	// ChaosMissingIdentifier removes an identifier.
+	ChaosMissingIdentifier ChaosVariant = "missing_identifier"
+	// ChaosNumericPhone stores the phone as a JSON number (any identifier when the index has no phone).
+	ChaosNumericPhone ChaosVariant = "numeric_phone"
+	// ChaosEmoji mixes emoji, zero-width, bidi and combining characters into an identifier.
+	ChaosEmoji ChaosVariant = "emoji"
+	// ChaosOversizedString replaces an identifier with a very long string.
+	ChaosOversizedString ChaosVariant = "oversized_string"
+	// ChaosHugeArray replaces an identifier with a very large array.
+	ChaosHugeArray ChaosVariant = "huge_array"
+	// ChaosDuplicateID gives the document the ID of an earlier document.
+	ChaosDuplicateID ChaosVariant = "duplicate_id"
+)
+
+// ChaosVariants lists every variant.
+var ChaosVariants = []ChaosVariant{ChaosNullIdentifier, ChaosMissingIdentifier, ChaosNumericPhone, ChaosEmoji,
+	ChaosOversizedString, ChaosHugeArray, ChaosDuplicateID}
+
+// ParseChaosVariants reads a comma-separated list of variants; "" or "all" selects every variant.
+func ParseChaosVariants(list string) ([]ChaosVariant, error) {
+	if list == "" || list == "all" {
+		return ChaosVariants, nil
+	}
+	var out []ChaosVariant
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, v := range ChaosVariants {
+			if string(v) == name {
+				out = append(out, v)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(ChaosVariants))
+			for i, v := range ChaosVariants {
+				names[i] = string(v)
+			}
+			return nil, fmt.Errorf("unknown chaos variant %q (valid: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	return out, nil
+}
+
+// Defaults for ChaosProfile.
+const (
+	DefaultChaosStringSize = 64 * 1024
+	DefaultChaosArraySize  = 10000
+	DefaultChaosSamples    = 10
+)
+
+// ChaosProfile configures Chaos. Percent is the share of documents that are made malformed (0-100),
+// spread evenly over Variants. Zero sizes select the defaults.
+type ChaosProfile struct {
+	Percent    float64        `json:"percent"`
+	Variants   []ChaosVariant `json:"variants"`
+	StringSize int            `json:"string_size"`
+	ArraySize  int            `json:"array_size"`
+	// Samples is how many mutated documents per variant are kept for the report.
+	Samples int `json:"samples"`
+}
+
+// ChaosMutation records how one document was broken. ID is the document ID after the mutation, and Value
+// the malformed value, when there is one to search for.
+type ChaosMutation struct {
+	Index   int          `json:"index"`
+	Key     string       `json:"key"`
+	ID      string       `json:"id,omitempty"`
+	Variant ChaosVariant `json:"variant"`
+	Field   string       `json:"field"`
+	Value   interface{}  `json:"value,omitempty"`
+}
+
+// chaosTarget is an indexed field the mutations can address.
+type chaosTarget struct {
+	alias string
+	path  []string
+	array bool
+}
+
+// Chaos applies a ChaosProfile to the documents of a Generator. It is safe for concurrent use.
+type Chaos struct {
+	g       *Generator
+	profile ChaosProfile
+	index   *schema.Index
+	idField string
+	targets []chaosTarget
+	phone   *chaosTarget
+	id      *chaosTarget
+	idOf    func(j int) string
+
+	mu      sync.Mutex
+	counts  map[ChaosVariant]int64
+	samples map[ChaosVariant][]ChaosMutation
+}
+
+const chaosStream uint64 = 6
+
+// Chaos returns a Chaos for documents of idx. idAlias names the document ID field; idOf returns the ID of
+// document j, for ChaosDuplicateID.
+func (g *Generator) Chaos(profile ChaosProfile, idx *schema.Index, idAlias string, idOf func(j int) string) (*Chaos, error) {
+	if profile.Percent < 0 || profile.Percent > 100 {
+		return nil, fmt.Errorf("chaos percent must be between 0 and 100")
+	}
+	if len(profile.Variants) == 0 {
+		profile.Variants = ChaosVariants
+	}
+	if profile.StringSize <= 0 {
+		profile.StringSize = DefaultChaosStringSize
+	}
+	if profile.ArraySize <= 0 {
+		profile.ArraySize = DefaultChaosArraySize
+	}
+	if profile.Samples <= 0 {
+		profile.Samples = DefaultChaosSamples
+	}
+	c := &Chaos{g: g, profile: profile, index: idx, idField: idAlias, idOf: idOf, counts: map[ChaosVariant]int64{}, samples: map[ChaosVariant][]ChaosMutation{}}
+	for _, f := range idx.Fields {
+		if f.Type == schema.TypeNumeric || !strings.HasPrefix(f.Path, "$.") {
+			continue
+		}
+		t := chaosTarget{alias: f.Alias, path: strings.Split(strings.TrimPrefix(f.Path, "$."), ".")}
+		last := t.path[len(t.path)-1]
+		if strings.HasSuffix(last, "[*]") {
+			t.path[len(t.path)-1], t.array = strings.TrimSuffix(last, "[*]"), true
+		}
+		if strings.ContainsAny(strings.Join(t.path, "."), "[]*?@") {
+			continue
+		}
+		if f.Alias == idAlias {
+			c.id = &t
+			continue
+		}
+		c.targets = append(c.targets, t)
+	}
+	for k := range c.targets {
+		if c.targets[k].alias == "phone" {
+			c.phone = &c.targets[k]
+		}
+	}
+	if len(c.targets) == 0 {
+		return nil, fmt.Errorf("index %s has no identifier fields to break", idx.Name)
+	}
+	return c, nil
+}
+
+// Profile returns the profile in use, with defaults filled in.
+func (c *Chaos) Profile() ChaosProfile {
+	return c.profile
+}
+
+// Index returns the index the documents are written for.
+func (c *Chaos) Index() *schema.Index {
+	return c.index
+}
+
+// IDField returns the alias of the document ID field.
+func (c *Chaos) IDField() string {
+	return c.idField
+}
+
+// Apply returns document i, either unchanged or as a malformed map, with the mutation applied (nil when
+// the document was left alone).
+func (c *Chaos) Apply(i int, doc interface{}) (interface{}, *ChaosMutation, error) {
+	r := c.g.faker(chaosStream, i).Rand
+	if r.Float64()*100 >= c.profile.Percent {
+		return doc, nil, nil
+	}
+	variant := c.profile.Variants[r.Intn(len(c.profile.Variants))]
+	if variant == ChaosDuplicateID && (i == 0 || c.id == nil || c.idOf == nil) {
+		// Nothing to duplicate yet.
+		return doc, nil, nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, nil, err
+	}
+	target := c.targets[r.Intn(len(c.targets))]
+	mutation := &ChaosMutation{Index: i, Variant: variant, Field: target.alias}
+	switch variant {
+	case ChaosNullIdentifier:
+		setPath(m, target.path, nil)
+	case ChaosMissingIdentifier:
+		deletePath(m, target.path)
+	case ChaosNumericPhone:
+		if c.phone != nil {
+			target = *c.phone
+			mutation.Field = target.alias
+		}
+		phone := 2000000000 + r.Int63n(8000000000)
+		mutation.Value = phone
+		setPath(m, target.path, target.wrap(phone))
+	case ChaosEmoji:
+		value := junkString(r, fmt.Sprint(firstValue(getPath(m, target.path))))
+		mutation.Value = value
+		setPath(m, target.path, target.wrap(value))
+	case ChaosOversizedString:
+		value := randomLetters(r, c.profile.StringSize)
+		mutation.Value = value
+		setPath(m, target.path, target.wrap(value))
+	case ChaosHugeArray:
+		// Prefer a field that is indexed as an array.
+		for _, t := range c.targets {
+			if t.array {
+				target = t
+				mutation.Field = t.alias
+				break
+			}
+		}
+		values := make([]interface{}, c.profile.ArraySize)
+		for k := range values {
+			values[k] = fmt.Sprintf("v%d_%s", k, randomLetters(r, 6))
+		}
+		mutation.Value = values[0]
+		setPath(m, target.path, values)
+	case ChaosDuplicateID:
+		id := c.idOf(r.Intn(i))
+		mutation.Field = c.id.alias
+		mutation.Value = id
+		setPath(m, c.id.path, c.id.wrap(id))
+	}
+	if c.id != nil {
+		if id := firstValue(getPath(m, c.id.path)); id != nil {
+			mutation.ID = fmt.Sprint(id)
+		}
+	}
+	return m, mutation, nil
+}
+
+// Break applies the profile to document i, to be stored at key, and records the mutation. A nil Chaos
+// returns doc unchanged, so generators can call it whether or not a profile is set.
+func (c *Chaos) Break(i int, key string, doc interface{}) (interface{}, error) {
+	if c == nil {
+		return doc, nil
+	}
+	out, mutation, err := c.Apply(i, doc)
+	if err != nil {
+		return nil, err
+	}
+	c.Record(key, mutation)
+	return out, nil
+}
+
+// Record counts a mutation once its document has a key, and keeps it as a sample for the report.
+func (c *Chaos) Record(key string, mutation *ChaosMutation) {
+	if mutation == nil {
+		return
+	}
+	mutation.Key = key
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[mutation.Variant]++
+	if len(c.samples[mutation.Variant]) < c.profile.Samples {
+		c.samples[mutation.Variant] = append(c.samples[mutation.Variant], *mutation)
+	}
+}
+
+// Counts returns the number of documents broken per variant so far.
+func (c *Chaos) Counts() map[ChaosVariant]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[ChaosVariant]int64, len(c.counts))
+	for k, v := range c.counts {
+		out[k] = v
+	}
+	return out
+}
+
+// Samples returns the recorded sample mutations, in variant order.
+func (c *Chaos) Samples() []ChaosMutation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []ChaosMutation
+	for _, v := range ChaosVariants {
+		out = append(out, c.samples[v]...)
+	}
+	return out
+}
+
+// wrap returns value as the target's JSON shape: a one-element array for array fields.
+func (t chaosTarget) wrap(value interface{}) interface{} {
+	if t.array {
+		return []interface{}{value}
+	}
+	return value
+}
+
+func getPath(m map[string]interface{}, path []string) interface{} {
+	var cur interface{} = m
+	for _, p := range path {
+		obj, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = obj[p]
+	}
+	return cur
+}
+
+func setPath(m map[string]interface{}, path []string, value interface{}) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[p] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+func deletePath(m map[string]interface{}, path []string) {
+	for _, p := range path[:len(path)-1] {
+		next, ok := m[p].(map[string]interface{})
+		if !ok {
+			return
+		}
+		m = next
+	}
+	delete(m, path[len(path)-1])
+}
+
+func firstValue(v interface{}) interface{} {
+	if list, ok := v.([]interface{}); ok && len(list) > 0 {
+		return list[0]
+	}
+	return v
+}
+
+// junk are the characters ChaosEmoji mixes in: emoji (including a ZWJ sequence and a flag), zero-width
+// and bidi control characters, a combining accent, the replacement character and mathematical letters.
+var junk = []string{"😀", "🔥", "👩‍💻", "🇵🇹", "​", "‍", "‮", "́", "�", "𝓤𝓷𝓲", "ﬁ", "Ω"}
+
+func junkString(r *rand.Rand, s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	pos := r.Intn(len(runes) + 1)
+	b.WriteString(string(runes[:pos]))
+	for n := 1 + r.Intn(3); n > 0; n-- {
+		b.WriteString(junk[r.Intn(len(junk))])
+	}
+	b.WriteString(string(runes[pos:]))
+	return b.String()
+}
+
+func randomLetters(r *rand.Rand, n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz0123456789"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[r.Intn(len(letters))]
+	}
+	return string(b)
+}
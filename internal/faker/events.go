@@ -0,0 +1,264 @@
+package faker
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/brianvoe/gofakeit/v6"
+)
+
+// Event type catalogue.
+//
+// Every generated event is one of the types of an EventCatalogue, picked by relative frequency. A type
+// decides the event's source, which identifiers it carries (a subset of the event index's visitor_id,
+// call_id, chat_id, external_id, lead_id and tickets_id), whether it has web visitor_data, and the shape
+// of its data payload. DefaultEventCatalogue is a plausible mix for a contact-centre/web product; Mix
+// re-weights or narrows it, e.g. for --event-mix page_view=80,purchase=20.
+
+// Identifier keys events can carry.
+const (
+	IdentifierVisitorID  = "visitor_id"
+	IdentifierCallID     = "call_id"
+	IdentifierChatID     = "chat_id"
+	IdentifierExternalID = "external_id"
+	IdentifierLeadID     = "lead_id"
+	IdentifierTicketsID  = "tickets_id"
+)
+
+// EventType describes one kind of event.
+type EventType struct {
+	Name   string
+	Source string
+	// Weight is the type's relative frequency in its catalogue.
+	Weight float64
+	// Identifiers are the identifier keys events of this type carry.
+	Identifiers []string
+	// Web events carry visitor_data (page, referrer, UTM parameters, device and behaviour).
+	Web bool
+	// Payload builds the event's data.
+	Payload func(f *gofakeit.Faker) map[string]interface{}
+}
+
+// EventCatalogue is a set of event types with their frequencies.
+type EventCatalogue struct {
+	types []EventType
+	cdf   []float64
+}
+
+// NewEventCatalogue returns a catalogue of types. Types with a zero weight are left out.
+func NewEventCatalogue(types []EventType) (*EventCatalogue, error) {
+	c := &EventCatalogue{}
+	total := 0.0
+	for _, t := range types {
+		if t.Weight < 0 {
+			return nil, fmt.Errorf("event type %s: weight must not be negative", t.Name)
+		}
+		if t.Weight == 0 {
+			continue
+		}
+		total += t.Weight
+		c.types = append(c.types, t)
+		c.cdf = append(c.cdf, total)
+	}
+	if len(c.types) == 0 {
+		return nil, fmt.Errorf("event catalogue has no types with a positive weight")
+	}
+	return c, nil
+}
+
+// Types returns the catalogue's types.
+func (c *EventCatalogue) Types() []EventType {
+	return c.types
+}
+
+// Share returns the expected fraction of events of each type.
+func (c *EventCatalogue) Share() map[string]float64 {
+	total := c.cdf[len(c.cdf)-1]
+	out := make(map[string]float64, len(c.types))
+	for _, t := range c.types {
+		out[t.Name] = t.Weight / total
+	}
+	return out
+}
+
+// Mix returns a copy of the catalogue with new weights, given as "name=weight,name=weight". Only the
+// listed types are kept. "" returns the catalogue unchanged.
+func (c *EventCatalogue) Mix(spec string) (*EventCatalogue, error) {
+	if strings.TrimSpace(spec) == "" {
+		return c, nil
+	}
+	var types []EventType
+	seen := map[string]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		name, w, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, fmt.Errorf("event mix %q: expected name=weight", part)
+		}
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil || weight < 0 {
+			return nil, fmt.Errorf("event mix %q: weight must be a non-negative number", part)
+		}
+		t, ok := c.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown event type %q (valid: %s)", name, strings.Join(c.names(), ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("event mix lists %s twice", name)
+		}
+		seen[name] = true
+		t.Weight = weight
+		types = append(types, t)
+	}
+	return NewEventCatalogue(types)
+}
+
+func (c *EventCatalogue) lookup(name string) (EventType, bool) {
+	for _, t := range c.types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return EventType{}, false
+}
+
+func (c *EventCatalogue) names() []string {
+	names := make([]string, len(c.types))
+	for i, t := range c.types {
+		names[i] = t.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pick returns a type chosen by weight.
+func (c *EventCatalogue) pick(r *rand.Rand) EventType {
+	u := r.Float64() * c.cdf[len(c.cdf)-1]
+	return c.types[min(sort.SearchFloat64s(c.cdf, u), len(c.types)-1)]
+}
+
+// defaultEventCatalogue is built once; DefaultEventCatalogue returns it.
+var defaultEventCatalogue *EventCatalogue
+
+func init() {
+	var err error
+	defaultEventCatalogue, err = NewEventCatalogue(defaultEventTypes)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// DefaultEventCatalogue returns the built-in event types: page_view, chat_message, call_started,
+// form_submit, ticket_opened and purchase.
+func DefaultEventCatalogue() *EventCatalogue {
+	return defaultEventCatalogue
+}
+
+var defaultEventTypes = []EventType{
+	{
+		Name: "page_view", Source: "web", Weight: 55, Web: true,
+		Identifiers: []string{IdentifierVisitorID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			return map[string]interface{}{
+				"cookie":     "cookie_" + f.LetterN(8),
+				"page_title": f.Sentence(3),
+				"load_ms":    f.Number(80, 4000),
+			}
+		},
+	},
+	{
+		Name: "chat_message", Source: "chat", Weight: 15, Web: true,
+		Identifiers: []string{IdentifierVisitorID, IdentifierChatID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			return map[string]interface{}{
+				"sender":        f.RandomString([]string{"visitor", "agent", "bot"}),
+				"channel":       f.RandomString([]string{"web", "whatsapp", "messenger"}),
+				"message":       f.Sentence(f.Number(3, 20)),
+				"message_index": f.Number(1, 40),
+			}
+		},
+	},
+	{
+		Name: "call_started", Source: "telephony", Weight: 10,
+		Identifiers: []string{IdentifierCallID, IdentifierExternalID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			return map[string]interface{}{
+				"phone":         f.Phone(),
+				"dialed_number": f.Phone(),
+				"direction":     f.RandomString([]string{"inbound", "outbound"}),
+				"queue":         f.RandomString([]string{"sales", "support", "billing", "retention"}),
+				"ring_ms":       f.Number(500, 30000),
+			}
+		},
+	},
+	{
+		Name: "form_submit", Source: "web", Weight: 10, Web: true,
+		Identifiers: []string{IdentifierVisitorID, IdentifierLeadID, IdentifierExternalID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			return map[string]interface{}{
+				"form_id":   "form_" + f.LetterN(4),
+				"form_name": f.RandomString([]string{"contact", "newsletter", "demo_request", "quote", "callback"}),
+				"name":      f.Name(),
+				"email":     f.Email(),
+				"phone":     f.Phone(),
+				"consent":   f.Bool(),
+			}
+		},
+	},
+	{
+		Name: "ticket_opened", Source: "helpdesk", Weight: 6,
+		Identifiers: []string{IdentifierTicketsID, IdentifierExternalID, IdentifierLeadID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			return map[string]interface{}{
+				"subject":  f.Sentence(5),
+				"priority": f.RandomString([]string{"low", "normal", "normal", "high", "urgent"}),
+				"category": f.RandomString([]string{"billing", "technical", "account", "shipping"}),
+				"channel":  f.RandomString([]string{"email", "phone", "chat", "web"}),
+				"email":    f.Email(),
+			}
+		},
+	},
+	{
+		Name: "purchase", Source: "ecommerce", Weight: 4, Web: true,
+		Identifiers: []string{IdentifierVisitorID, IdentifierExternalID, IdentifierLeadID},
+		Payload: func(f *gofakeit.Faker) map[string]interface{} {
+			n := f.Number(1, 5)
+			items := make([]map[string]interface{}, n)
+			total := 0.0
+			for k := range items {
+				price := f.Price(2, 300)
+				qty := f.Number(1, 3)
+				total += price * float64(qty)
+				items[k] = map[string]interface{}{"sku": "SKU-" + f.DigitN(6), "quantity": qty, "price": price}
+			}
+			return map[string]interface{}{
+				"order_id": "ord_" + f.LetterN(8),
+				"currency": f.RandomString([]string{"EUR", "USD", "GBP"}),
+				"amount":   float64(int(total*100+0.5)) / 100,
+				"items":    items,
+				"email":    f.Email(),
+			}
+		},
+	},
+}
+
+// newIdentifier returns a fresh value for an identifier key.
+func newIdentifier(f *gofakeit.Faker, key string) string {
+	switch key {
+	case IdentifierVisitorID:
+		return f.LetterN(3)
+	case IdentifierCallID:
+		return "call_" + f.LetterN(5)
+	case IdentifierChatID:
+		return "chat_" + f.LetterN(5)
+	case IdentifierExternalID:
+		return "ext_" + f.LetterN(5)
+	case IdentifierLeadID:
+		return "f2l_" + f.LetterN(5)
+	case IdentifierTicketsID:
+		return "ticket_" + f.LetterN(5)
+	}
+	return f.LetterN(5)
+}
@@ -8,20 +8,18 @@ import (
 	"github.com/brianvoe/gofakeit/v6"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-	gofakeit.Seed(time.Now().UnixNano())
-}
-
-// Event structure
+// Event structure (TimestampEpoch is Timestamp as Unix seconds, indexed as a NUMERIC field). EventType is
+// one of the types of an EventCatalogue, which decides Source, Data and which Identifiers are set;
+// VisitorData is only present on web events.
 type Event struct {
-	EventType   string                 `json:"event_type"`
-	EventID     string                 `json:"event_id"`
-	Timestamp   string                 `json:"timestamp"`
-	Source      string                 `json:"source"`
-	VisitorData map[string]interface{} `json:"visitor_data"`
-	Data        map[string]interface{} `json:"data"`
-	Identifiers map[string]interface{} `json:"identifiers"`
+	EventType      string                 `json:"event_type"`
+	EventID        string                 `json:"event_id"`
+	Timestamp      string                 `json:"timestamp"`
+	TimestampEpoch int64                  `json:"timestamp_epoch"`
+	Source         string                 `json:"source"`
+	VisitorData    map[string]interface{} `json:"visitor_data,omitempty"`
+	Data           map[string]interface{} `json:"data"`
+	Identifiers    map[string]interface{} `json:"identifiers"`
 }
 
 // Customer structure
@@ -39,76 +37,79 @@ type Customer struct {
 
 // Exported functions for random data generation
 func RandomEvent() Event {
-	visitorID := gofakeit.LetterN(3)
-	sessionID := gofakeit.LetterN(3)
-	return Event{
-		EventType: "visitor_event",
-		EventID:   "evt_" + gofakeit.LetterN(6),
-		Timestamp: RandomTimestamp(),
-		Source:    gofakeit.LetterN(8),
-		VisitorData: map[string]interface{}{
-			"behavior": map[string]interface{}{
-				"interactions": []string{"scroll", "click_cta", "hover", "form_submit"}[:rand.Intn(4)+1],
-				"pages_viewed": rand.Intn(10) + 1,
-				"time_on_site": rand.Intn(591) + 10,
-			},
-			"device_info": map[string]interface{}{
-				"device_type": []string{"desktop", "mobile", "tablet"}[rand.Intn(3)],
-				"ip_address":  fmt.Sprintf("192.168.%d.%d", rand.Intn(255), rand.Intn(255)),
-				"user_agent":  gofakeit.LetterN(10),
-			},
-			"page_url":   RandomURL(),
-			"referrer":   RandomReferrer(),
-			"session_id": sessionID,
-			"utm_params": map[string]interface{}{
-				"utm_campaign": "camp" + gofakeit.LetterN(5),
-				"utm_medium":   "med" + gofakeit.LetterN(5),
-				"utm_source":   "src" + gofakeit.LetterN(4),
-			},
-			"visitor_id": visitorID,
-		},
-		Data: map[string]interface{}{
-			"cookie": "cookie_" + gofakeit.LetterN(8),
-			"email":  gofakeit.LetterN(10) + "@example.com",
-			"phone":  gofakeit.LetterN(10),
-		},
-		Identifiers: map[string]interface{}{
-			"call_id":      "call_" + gofakeit.LetterN(5),
-			"chat_id":      "chat_" + gofakeit.LetterN(5),
-			"external_id":  "ext_" + gofakeit.LetterN(5),
-			"lead_id": "f2l_" + gofakeit.LetterN(5),
-			"tickets_id":   "ticket_" + gofakeit.LetterN(5),
-			"visitor_id":           visitorID,
-		},
-	}
+	return NewRandomGenerator().Event(0)
 }
 
 func RandomCustomer() Customer {
-	visitorIDs := []string{gofakeit.UUID(), gofakeit.UUID()}
-	sessionIDs := []string{gofakeit.UUID(), gofakeit.UUID()}
-	email := gofakeit.Email()
-	phone := gofakeit.Phone()
+	return NewRandomGenerator().Customer(0)
+}
+
+// newEvent builds an event of a type drawn from catalogue, with a timestamp up to ~7 days before now
+func newEvent(f *gofakeit.Faker, now time.Time, catalogue *EventCatalogue) Event {
+	r := f.Rand
+	t := catalogue.pick(r)
+	ts := randomTime(r, now)
+	ev := Event{
+		EventType:      t.Name,
+		EventID:        "evt_" + f.LetterN(6),
+		Timestamp:      ts.Format("2006-01-02T15:04:05Z"),
+		TimestampEpoch: ts.Unix(),
+		Source:         t.Source,
+		Data:           t.Payload(f),
+		Identifiers:    make(map[string]interface{}, len(t.Identifiers)),
+	}
+	for _, key := range t.Identifiers {
+		ev.Identifiers[key] = newIdentifier(f, key)
+	}
+	if t.Web {
+		visitorID, ok := ev.Identifiers[IdentifierVisitorID].(string)
+		if !ok {
+			visitorID = newIdentifier(f, IdentifierVisitorID)
+		}
+		ev.VisitorData = map[string]interface{}{
+			"behavior":    randomBehavior(r),
+			"device_info": randomDeviceInfo(r),
+			"page_url":    randomURL(r),
+			"referrer":    randomReferrer(r),
+			"session_id":  f.LetterN(3),
+			"utm_params":  randomUTM(r),
+			"visitor_id":  visitorID,
+		}
+	}
+	return ev
+}
+
+// newCustomer builds a customer from f, created and updated between 1900 and now
+func newCustomer(f *gofakeit.Faker, now time.Time) Customer {
+	visitorIDs := []string{f.UUID(), f.UUID()}
+	sessionIDs := []string{f.UUID(), f.UUID()}
+	callIDs := []string{"call_" + f.LetterN(5)}
+	chatIDs := []string{"chat_" + f.LetterN(5)}
+	email := f.Email()
+	phone := f.Phone()
 	return Customer{
-		CustomerID: gofakeit.UUID(),
-		CreatedAt:  gofakeit.Date().Format(time.RFC3339),
-		UpdatedAt:  gofakeit.Date().Format(time.RFC3339),
-		Merged:     gofakeit.Number(0, 1),
-		Deleted:    gofakeit.Number(0, 1),
+		CustomerID: f.UUID(),
+		CreatedAt:  f.DateRange(oldestDate, now).Format(time.RFC3339),
+		UpdatedAt:  f.DateRange(oldestDate, now).Format(time.RFC3339),
+		Merged:     f.Number(0, 1),
+		Deleted:    f.Number(0, 1),
 		Identifiers: map[string]interface{}{
 			"visitor_ids": visitorIDs,
 			"session_ids": sessionIDs,
+			"call_ids":    callIDs,
+			"chat_ids":    chatIDs,
 		},
 		PrimaryIdentifiers: map[string]interface{}{
 			"email": email,
 			"phone": phone,
 		},
 		PersonalData: map[string]interface{}{
-			"name":              gofakeit.Name(),
-			"company":           gofakeit.Company(),
-			"title":             gofakeit.JobTitle(),
-			"inferred_location": gofakeit.City() + ", " + gofakeit.Country(),
+			"name":              f.Name(),
+			"company":           f.Company(),
+			"title":             f.JobTitle(),
+			"inferred_location": f.City() + ", " + f.Country(),
 		},
-		ConfidenceScore: gofakeit.Float64Range(0.6, 1.0),
+		ConfidenceScore: f.Float64Range(0.6, 1.0),
 	}
 }
 func RandomInt(min, max int) int {
@@ -121,69 +122,106 @@ func RandomFloat(min, max float64) float64 {
 }
 
 func MaybeEmpty(value string) string {
-	if rand.Float64() > 0.3 {
+	return maybeEmpty(globalRand, value)
+}
+
+func maybeEmpty(r *rand.Rand, value string) string {
+	if r.Float64() > 0.3 {
 		return value
 	}
 	return ""
 }
 
 func RandomString(prefix string, length int) string {
+	return randomString(globalRand, prefix, length)
+}
+
+func randomString(r *rand.Rand, prefix string, length int) string {
 	letters := []rune("abcdefghijklmnopqrstuvwxyz0123456789")
 	b := make([]rune, length)
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		b[i] = letters[r.Intn(len(letters))]
 	}
 	return prefix + string(b)
 }
 
 func RandomURL() string {
-	return fmt.Sprintf("https://%s.com/%s", RandomString("site", 5), RandomString("page", 10))
+	return randomURL(globalRand)
+}
+
+func randomURL(r *rand.Rand) string {
+	return fmt.Sprintf("https://%s.com/%s", randomString(r, "site", 5), randomString(r, "page", 10))
 }
 
 func RandomReferrer() string {
+	return randomReferrer(globalRand)
+}
+
+func randomReferrer(r *rand.Rand) string {
 	referrers := []string{
-		RandomURL(),
+		randomURL(r),
 		"/internal/path",
 		"",
 	}
-	return referrers[rand.Intn(len(referrers))]
+	return referrers[r.Intn(len(referrers))]
 }
 
 func RandomUTM() map[string]string {
-	if rand.Float64() < 0.4 {
+	return randomUTM(globalRand)
+}
+
+func randomUTM(r *rand.Rand) map[string]string {
+	if r.Float64() < 0.4 {
 		return map[string]string{}
 	}
 	return map[string]string{
-		"utm_source":   MaybeEmpty(RandomString("src", 10)),
-		"utm_medium":   MaybeEmpty(RandomString("med", 10)),
-		"utm_campaign": MaybeEmpty(RandomString("camp", 10)),
+		"utm_source":   maybeEmpty(r, randomString(r, "src", 10)),
+		"utm_medium":   maybeEmpty(r, randomString(r, "med", 10)),
+		"utm_campaign": maybeEmpty(r, randomString(r, "camp", 10)),
 	}
 }
 
 func RandomDeviceInfo() map[string]string {
-	if rand.Float64() < 0.2 {
+	return randomDeviceInfo(globalRand)
+}
+
+func randomDeviceInfo(r *rand.Rand) map[string]string {
+	if r.Float64() < 0.2 {
 		return map[string]string{}
 	}
 	return map[string]string{
-		"user_agent":  MaybeEmpty(RandomString("ua", 20)),
-		"ip_address":  MaybeEmpty(fmt.Sprintf("192.168.%d.%d", rand.Intn(255), rand.Intn(255))),
-		"device_type": MaybeEmpty([]string{"desktop", "mobile", "tablet", ""}[rand.Intn(4)]),
+		"user_agent":  maybeEmpty(r, randomString(r, "ua", 20)),
+		"ip_address":  maybeEmpty(r, fmt.Sprintf("192.168.%d.%d", r.Intn(255), r.Intn(255))),
+		"device_type": maybeEmpty(r, []string{"desktop", "mobile", "tablet", ""}[r.Intn(4)]),
 	}
 }
 
 func RandomBehavior() map[string]interface{} {
-	if rand.Float64() < 0.2 {
+	return randomBehavior(globalRand)
+}
+
+func randomBehavior(r *rand.Rand) map[string]interface{} {
+	if r.Float64() < 0.2 {
 		return map[string]interface{}{}
 	}
 	return map[string]interface{}{
-		"pages_viewed": rand.Intn(10) + 1,
-		"time_on_site": rand.Intn(591) + 10,
-		"interactions": []string{"scroll", "click_cta", "hover", "form_submit", "video_play"}[:rand.Intn(4)+1],
+		"pages_viewed": r.Intn(10) + 1,
+		"time_on_site": r.Intn(591) + 10,
+		"interactions": []string{"scroll", "click_cta", "hover", "form_submit", "video_play"}[:r.Intn(4)+1],
 	}
 }
 
+// RandomTime returns a UTC time up to ~7 days in the past, truncated to the second
+func RandomTime() time.Time {
+	return randomTime(globalRand, time.Now())
+}
+
+func randomTime(r *rand.Rand, now time.Time) time.Time {
+	now = now.UTC().Truncate(time.Second)
+	delta := time.Duration(r.Intn(10000)-10000) * time.Minute
+	return now.Add(delta)
+}
+
 func RandomTimestamp() string {
-	now := time.Now().UTC()
-	delta := time.Duration(rand.Intn(10000)-10000) * time.Minute
-	return now.Add(delta).Format("2006-01-02T15:04:05Z")
+	return RandomTime().Format("2006-01-02T15:04:05Z")
 }
@@ -0,0 +1,181 @@
+package faker
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Linked datasets.
+//
+// A Population is customers 0..size-1 of a Generator. Its events belong to those customers: the visitor
+// and session IDs, email, phone and call/chat IDs of event i (those its type carries) are taken from the
+// customer that owns it, so events can be joined to customers on any identifier. How many events each
+// customer owns follows a Distribution.
+
+// Distribution selects how events are spread over the customers of a population.
+type Distribution string
+
+const (
+	// DistUniform gives every customer the same expected number of events.
+	DistUniform Distribution = "uniform"
+	// DistZipf gives customer j a weight of 1/(j+1)^s: customer 0 owns the most events, then customer 1...
+	DistZipf Distribution = "zipf"
+	// DistPowerLaw draws each customer's weight from a Pareto distribution with shape alpha: most customers
+	// have a few events and a random few have very many.
+	DistPowerLaw Distribution = "powerlaw"
+)
+
+// Distributions lists the valid distributions.
+var Distributions = []Distribution{DistUniform, DistZipf, DistPowerLaw}
+
+// Default exponents: s for DistZipf, alpha for DistPowerLaw.
+const (
+	DefaultZipfExponent     = 1.0
+	DefaultPowerLawExponent = 2.0
+)
+
+// ParseDistribution validates a distribution name; "" selects DistUniform.
+func ParseDistribution(s string) (Distribution, error) {
+	if s == "" {
+		return DistUniform, nil
+	}
+	for _, d := range Distributions {
+		if string(d) == s {
+			return d, nil
+		}
+	}
+	names := make([]string, len(Distributions))
+	for i, d := range Distributions {
+		names[i] = string(d)
+	}
+	return "", fmt.Errorf("unknown distribution %q (valid: %s)", s, strings.Join(names, ", "))
+}
+
+// Streams used by populations, next to customerStream and eventStream.
+const (
+	linkedEventStream uint64 = 3
+	weightStream      uint64 = 4
+)
+
+// Population is a set of customers whose events share their identifiers. It is safe for concurrent use.
+type Population struct {
+	g        *Generator
+	size     int
+	dist     Distribution
+	exponent float64
+	// cdf holds the cumulative customer weights; it is nil for DistUniform.
+	cdf []float64
+}
+
+// Population returns the population of customers 0..size-1 of g. exponent is the Zipf s or power-law
+// alpha; 0 selects the default. The weights take 8 bytes per customer.
+func (g *Generator) Population(size int, dist Distribution, exponent float64) (*Population, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("population must have at least one customer")
+	}
+	if exponent < 0 || math.IsNaN(exponent) || math.IsInf(exponent, 0) {
+		return nil, fmt.Errorf("exponent must be a positive number")
+	}
+	p := &Population{g: g, size: size, dist: dist, exponent: exponent}
+	switch dist {
+	case DistUniform:
+		return p, nil
+	case DistZipf:
+		if p.exponent == 0 {
+			p.exponent = DefaultZipfExponent
+		}
+	case DistPowerLaw:
+		if p.exponent == 0 {
+			p.exponent = DefaultPowerLawExponent
+		}
+	default:
+		return nil, fmt.Errorf("unknown distribution %q", dist)
+	}
+	p.cdf = make([]float64, size)
+	total := 0.0
+	for j := range p.cdf {
+		total += p.weight(j)
+		p.cdf[j] = total
+	}
+	return p, nil
+}
+
+// weight returns the relative number of events of customer j.
+func (p *Population) weight(j int) float64 {
+	if p.dist == DistZipf {
+		return 1 / math.Pow(float64(j+1), p.exponent)
+	}
+	// Pareto(alpha) by inversion, from a uniform value in (0, 1] derived from the seed and j.
+	u := float64(splitmix64(splitmix64(uint64(p.g.seed)+weightStream)+uint64(j))>>11+1) / (1 << 53)
+	return math.Pow(u, -1/p.exponent)
+}
+
+// Size returns the number of customers.
+func (p *Population) Size() int {
+	return p.size
+}
+
+// Exponent returns the exponent in use (after defaults), or 0 for DistUniform.
+func (p *Population) Exponent() float64 {
+	if p.dist == DistUniform {
+		return 0
+	}
+	return p.exponent
+}
+
+// Customer returns customer j; it is the same document as Generator.Customer(j).
+func (p *Population) Customer(j int) Customer {
+	return p.g.Customer(j)
+}
+
+// Event returns event i, owned by customer Owner(i).
+func (p *Population) Event(i int) Event {
+	f := p.g.faker(linkedEventStream, i)
+	owner := p.pick(f.Rand)
+	ev := newEvent(f, p.g.now, p.g.events)
+	linkEvent(&ev, p.g.Customer(owner), f.Rand)
+	return ev
+}
+
+// Owner returns the index of the customer that owns event i.
+func (p *Population) Owner(i int) int {
+	return p.pick(p.g.faker(linkedEventStream, i).Rand)
+}
+
+func (p *Population) pick(r *rand.Rand) int {
+	if p.cdf == nil {
+		return r.Intn(p.size)
+	}
+	u := r.Float64() * p.cdf[len(p.cdf)-1]
+	return min(sort.SearchFloat64s(p.cdf, u), p.size-1)
+}
+
+// linkEvent replaces the identifiers of ev with ones belonging to c. Only the identifiers and contact
+// details the event's type carries are replaced, so the event keeps its type's shape.
+func linkEvent(ev *Event, c Customer, r *rand.Rand) {
+	pick := func(key string) string {
+		ids, _ := c.Identifiers[key].([]string)
+		if len(ids) == 0 {
+			return ""
+		}
+		return ids[r.Intn(len(ids))]
+	}
+	visitorID := pick("visitor_ids")
+	replace := func(m map[string]interface{}, key string, value interface{}) {
+		if _, ok := m[key]; ok {
+			m[key] = value
+		}
+	}
+	replace(ev.Identifiers, IdentifierVisitorID, visitorID)
+	replace(ev.Identifiers, IdentifierCallID, pick("call_ids"))
+	replace(ev.Identifiers, IdentifierChatID, pick("chat_ids"))
+	if ev.VisitorData != nil {
+		ev.VisitorData["visitor_id"] = visitorID
+		ev.VisitorData["session_id"] = pick("session_ids")
+	}
+	replace(ev.Data, "email", c.PrimaryIdentifiers["email"])
+	replace(ev.Data, "phone", c.PrimaryIdentifiers["phone"])
+}
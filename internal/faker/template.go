@@ -0,0 +1,471 @@
+package faker
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"gopkg.in/yaml.v3"
+)
+
+// Template-driven documents.
+//
+// A Template describes an arbitrary JSON document shape in YAML or JSON, for example:
+//
+//	name: order
+//	prefix: "order:"
+//	id_field: orderId
+//	fields:
+//	  orderId:   {fake: uuid}
+//	  status:    {enum: [pending, paid, shipped], weights: [1, 6, 3]}
+//	  total:     {float: {min: 5, max: 900, distribution: powerlaw, exponent: 1.5, decimals: 2}}
+//	  createdAt: {time: {within: 720h}}
+//	  coupon:    {pattern: "SAVE-####", probability: 0.2}
+//	  customer:
+//	    fields:
+//	      email: {fake: email}
+//	      name:  {generate: "{firstname} {lastname}"}
+//	  items:
+//	    array:
+//	      min: 1
+//	      max: 5
+//	      items:
+//	        fields:
+//	          sku: {pattern: "SKU-???-####"}
+//	          qty: {int: {min: 1, max: 10, distribution: zipf}}
+//
+// Each field sets exactly one of: fake (a gofakeit function, with optional params), generate (a gofakeit
+// template string), pattern (# is a digit, ? a letter), const, enum (with optional weights), bool (the
+// probability of true), int, float, time, fields (a nested object) or array. probability is the chance
+// that the field is present at all (default 1). Numbers use the uniform (default), normal, zipf or
+// powerlaw distribution between min and max. Times fall between from and to (RFC 3339) or within a
+// duration before the generator's reference time, and are formatted as rfc3339 (default), unix,
+// unix_ms, date or a Go time layout.
+
+// Template is a parsed document template. Create it with ParseTemplate or LoadTemplate.
+type Template struct {
+	Name    string                    `json:"name" yaml:"name"`
+	Prefix  string                    `json:"prefix" yaml:"prefix"`
+	IDField string                    `json:"id_field" yaml:"id_field"`
+	Fields  map[string]*TemplateField `json:"fields" yaml:"fields"`
+
+	root valueFunc
+}
+
+// TemplateField describes how one field is generated.
+type TemplateField struct {
+	Fake        string                    `json:"fake" yaml:"fake"`
+	Params      map[string]string         `json:"params" yaml:"params"`
+	Generate    string                    `json:"generate" yaml:"generate"`
+	Pattern     string                    `json:"pattern" yaml:"pattern"`
+	Const       interface{}               `json:"const" yaml:"const"`
+	Enum        []interface{}             `json:"enum" yaml:"enum"`
+	Weights     []float64                 `json:"weights" yaml:"weights"`
+	Bool        *float64                  `json:"bool" yaml:"bool"`
+	Int         *NumberSpec               `json:"int" yaml:"int"`
+	Float       *NumberSpec               `json:"float" yaml:"float"`
+	Time        *TimeSpec                 `json:"time" yaml:"time"`
+	Fields      map[string]*TemplateField `json:"fields" yaml:"fields"`
+	Array       *ArraySpec                `json:"array" yaml:"array"`
+	Probability *float64                  `json:"probability" yaml:"probability"`
+}
+
+// NumberSpec is an int or float range. Mean and StdDev apply to the normal distribution (defaults: the
+// middle of the range and a sixth of its width), Exponent to zipf (s > 1, default 1.1) and powerlaw
+// (alpha, default 2; a Pareto distribution with scale Min). Values are clamped to [Min, Max]. Decimals
+// rounds floats.
+type NumberSpec struct {
+	Min          float64 `json:"min" yaml:"min"`
+	Max          float64 `json:"max" yaml:"max"`
+	Distribution string  `json:"distribution" yaml:"distribution"`
+	Exponent     float64 `json:"exponent" yaml:"exponent"`
+	Mean         float64 `json:"mean" yaml:"mean"`
+	StdDev       float64 `json:"stddev" yaml:"stddev"`
+	Decimals     *int    `json:"decimals" yaml:"decimals"`
+}
+
+// TimeSpec is a time range: From/To in RFC 3339, or Within (a Go duration such as 168h) before the
+// generator's reference time. The default is within 720h.
+type TimeSpec struct {
+	From   string `json:"from" yaml:"from"`
+	To     string `json:"to" yaml:"to"`
+	Within string `json:"within" yaml:"within"`
+	Format string `json:"format" yaml:"format"`
+}
+
+// ArraySpec generates between Min and Max elements described by Items.
+type ArraySpec struct {
+	Min   int            `json:"min" yaml:"min"`
+	Max   int            `json:"max" yaml:"max"`
+	Items *TemplateField `json:"items" yaml:"items"`
+}
+
+// valueFunc produces one value from a document's faker and the generator's reference time.
+type valueFunc func(f *gofakeit.Faker, now time.Time) (interface{}, error)
+
+// ParseTemplate reads a template from YAML or JSON and checks every field.
+func ParseTemplate(data []byte) (*Template, error) {
+	var t Template
+	if err := yaml.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	if len(t.Fields) == 0 {
+		return nil, fmt.Errorf("template has no fields")
+	}
+	if t.IDField != "" && t.Fields[t.IDField] == nil {
+		return nil, fmt.Errorf("id_field %q is not a top-level field", t.IDField)
+	}
+	if t.Prefix == "" && t.Name != "" {
+		t.Prefix = t.Name + ":"
+	}
+	root, err := compileObject("", t.Fields)
+	if err != nil {
+		return nil, err
+	}
+	t.root = root
+	return &t, nil
+}
+
+// LoadTemplate reads a template file.
+func LoadTemplate(path string) (*Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read template: %w", err)
+	}
+	t, err := ParseTemplate(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// ID returns the value of the template's id_field in doc, for the docid key strategy.
+func (t *Template) ID(doc map[string]interface{}) string {
+	if t.IDField == "" || doc[t.IDField] == nil {
+		return ""
+	}
+	return fmt.Sprint(doc[t.IDField])
+}
+
+// Document returns document i of template t.
+func (g *Generator) Document(t *Template, i int) (map[string]interface{}, error) {
+	v, err := t.root(g.faker(templateStream, i), g.now)
+	if err != nil {
+		return nil, err
+	}
+	return v.(map[string]interface{}), nil
+}
+
+const templateStream uint64 = 5
+
+// compileObject compiles a nested object. Fields are generated in name order so that a seed always
+// draws the same random numbers for the same field.
+func compileObject(path string, fields map[string]*TemplateField) (valueFunc, error) {
+	type entry struct {
+		name    string
+		present float64
+		gen     valueFunc
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	entries := make([]entry, 0, len(names))
+	for _, name := range names {
+		spec := fields[name]
+		fieldPath := strings.TrimPrefix(path+"."+name, ".")
+		if spec == nil {
+			return nil, fmt.Errorf("field %s: empty definition", fieldPath)
+		}
+		gen, err := compileField(fieldPath, spec)
+		if err != nil {
+			return nil, err
+		}
+		present := 1.0
+		if spec.Probability != nil {
+			present = *spec.Probability
+			if present < 0 || present > 1 {
+				return nil, fmt.Errorf("field %s: probability must be between 0 and 1", fieldPath)
+			}
+		}
+		entries = append(entries, entry{name: name, present: present, gen: gen})
+	}
+	return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+		doc := make(map[string]interface{}, len(entries))
+		for _, e := range entries {
+			if e.present < 1 && f.Rand.Float64() >= e.present {
+				continue
+			}
+			v, err := e.gen(f, now)
+			if err != nil {
+				return nil, err
+			}
+			doc[e.name] = v
+		}
+		return doc, nil
+	}, nil
+}
+
+func compileField(path string, spec *TemplateField) (valueFunc, error) {
+	kinds := 0
+	for _, set := range []bool{spec.Fake != "", spec.Generate != "", spec.Pattern != "", spec.Const != nil, spec.Enum != nil,
+		spec.Bool != nil, spec.Int != nil, spec.Float != nil, spec.Time != nil, spec.Fields != nil, spec.Array != nil} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("field %s: set exactly one of fake, generate, pattern, const, enum, bool, int, float, time, fields or array", path)
+	}
+	switch {
+	case spec.Fake != "":
+		info := gofakeit.GetFuncLookup(spec.Fake)
+		if info == nil {
+			return nil, fmt.Errorf("field %s: unknown gofakeit function %q", path, spec.Fake)
+		}
+		var params *gofakeit.MapParams
+		if len(spec.Params) > 0 {
+			params = gofakeit.NewMapParams()
+			for k, v := range spec.Params {
+				params.Add(k, v)
+			}
+		}
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			v, err := info.Generate(f.Rand, params, info)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", path, err)
+			}
+			return v, nil
+		}, nil
+	case spec.Generate != "":
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			return f.Generate(spec.Generate), nil
+		}, nil
+	case spec.Pattern != "":
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			return f.Lexify(f.Numerify(spec.Pattern)), nil
+		}, nil
+	case spec.Const != nil:
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			return spec.Const, nil
+		}, nil
+	case spec.Enum != nil:
+		return compileEnum(path, spec.Enum, spec.Weights)
+	case spec.Bool != nil:
+		p := *spec.Bool
+		if p < 0 || p > 1 {
+			return nil, fmt.Errorf("field %s: bool must be the probability of true, between 0 and 1", path)
+		}
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			return f.Rand.Float64() < p, nil
+		}, nil
+	case spec.Int != nil:
+		return compileNumber(path, spec.Int, true)
+	case spec.Float != nil:
+		return compileNumber(path, spec.Float, false)
+	case spec.Time != nil:
+		return compileTime(path, spec.Time)
+	case spec.Fields != nil:
+		return compileObject(path, spec.Fields)
+	default:
+		return compileArray(path, spec.Array)
+	}
+}
+
+func compileEnum(path string, values []interface{}, weights []float64) (valueFunc, error) {
+	if len(values) == 0 {
+		return nil, fmt.Errorf("field %s: enum is empty", path)
+	}
+	if weights == nil {
+		return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+			return values[f.Rand.Intn(len(values))], nil
+		}, nil
+	}
+	if len(weights) != len(values) {
+		return nil, fmt.Errorf("field %s: enum has %d values but %d weights", path, len(values), len(weights))
+	}
+	cdf := make([]float64, len(weights))
+	total := 0.0
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("field %s: weights must be non-negative numbers", path)
+		}
+		total += w
+		cdf[i] = total
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("field %s: weights must not all be zero", path)
+	}
+	return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+		u := f.Rand.Float64() * total
+		return values[min(sort.SearchFloat64s(cdf, u), len(values)-1)], nil
+	}, nil
+}
+
+func compileNumber(path string, spec *NumberSpec, integer bool) (valueFunc, error) {
+	lo, hi := spec.Min, spec.Max
+	if math.IsNaN(lo) || math.IsInf(lo, 0) || math.IsNaN(hi) || math.IsInf(hi, 0) {
+		return nil, fmt.Errorf("field %s: min and max must be finite numbers", path)
+	}
+	if hi < lo {
+		return nil, fmt.Errorf("field %s: max must not be below min", path)
+	}
+	// Integer draws go through int64, so keep max - min well inside its range.
+	if integer && hi-lo >= 1<<62 {
+		return nil, fmt.Errorf("field %s: max - min must be below 2^62", path)
+	}
+	var draw func(r *rand.Rand) float64
+	switch spec.Distribution {
+	case "", string(DistUniform):
+		draw = func(r *rand.Rand) float64 {
+			if integer {
+				return lo + float64(r.Int63n(int64(hi-lo)+1))
+			}
+			return lo + r.Float64()*(hi-lo)
+		}
+	case "normal":
+		mean, stddev := spec.Mean, spec.StdDev
+		if mean == 0 && stddev == 0 {
+			mean, stddev = (lo+hi)/2, (hi-lo)/6
+		}
+		if stddev < 0 {
+			return nil, fmt.Errorf("field %s: stddev must not be negative", path)
+		}
+		draw = func(r *rand.Rand) float64 {
+			return mean + r.NormFloat64()*stddev
+		}
+	case string(DistZipf):
+		s := spec.Exponent
+		if s == 0 {
+			s = 1.1
+		}
+		if s <= 1 {
+			return nil, fmt.Errorf("field %s: zipf exponent must be greater than 1", path)
+		}
+		draw = func(r *rand.Rand) float64 {
+			return lo + float64(rand.NewZipf(r, s, 1, uint64(hi-lo)).Uint64())
+		}
+	case string(DistPowerLaw):
+		alpha := spec.Exponent
+		if alpha == 0 {
+			alpha = DefaultPowerLawExponent
+		}
+		if alpha < 0 {
+			return nil, fmt.Errorf("field %s: powerlaw exponent must be positive", path)
+		}
+		// Pareto(alpha) with scale min, so most values are close to min; for min <= 0 the tail starts at
+		// min with scale 1.
+		draw = func(r *rand.Rand) float64 {
+			x := math.Pow(1-r.Float64(), -1/alpha)
+			if lo > 0 {
+				return lo * x
+			}
+			return lo + x - 1
+		}
+	default:
+		return nil, fmt.Errorf("field %s: unknown distribution %q (valid: uniform, normal, zipf, powerlaw)", path, spec.Distribution)
+	}
+	scale := 0.0
+	if spec.Decimals != nil {
+		if integer || *spec.Decimals < 0 {
+			return nil, fmt.Errorf("field %s: decimals applies to non-negative float precision only", path)
+		}
+		scale = math.Pow(10, float64(*spec.Decimals))
+	}
+	return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+		v := math.Max(lo, math.Min(hi, draw(f.Rand)))
+		if integer {
+			return int64(math.Round(v)), nil
+		}
+		if scale > 0 {
+			v = math.Round(v*scale) / scale
+		}
+		return v, nil
+	}, nil
+}
+
+func compileTime(path string, spec *TimeSpec) (valueFunc, error) {
+	var from, to time.Time
+	var within time.Duration
+	var err error
+	switch {
+	case spec.From != "" || spec.To != "":
+		if spec.From == "" || spec.To == "" || spec.Within != "" {
+			return nil, fmt.Errorf("field %s: time needs both from and to, or within", path)
+		}
+		if from, err = time.Parse(time.RFC3339, spec.From); err != nil {
+			return nil, fmt.Errorf("field %s: from: %w", path, err)
+		}
+		if to, err = time.Parse(time.RFC3339, spec.To); err != nil {
+			return nil, fmt.Errorf("field %s: to: %w", path, err)
+		}
+		if to.Before(from) {
+			return nil, fmt.Errorf("field %s: to is before from", path)
+		}
+	default:
+		within = 720 * time.Hour
+		if spec.Within != "" {
+			if within, err = time.ParseDuration(spec.Within); err != nil || within < 0 {
+				return nil, fmt.Errorf("field %s: within must be a positive duration such as 168h", path)
+			}
+		}
+	}
+	var format func(t time.Time) interface{}
+	switch spec.Format {
+	case "", "rfc3339":
+		format = func(t time.Time) interface{} { return t.Format(time.RFC3339) }
+	case "unix":
+		format = func(t time.Time) interface{} { return t.Unix() }
+	case "unix_ms":
+		format = func(t time.Time) interface{} { return t.UnixMilli() }
+	case "date":
+		format = func(t time.Time) interface{} { return t.Format("2006-01-02") }
+	default:
+		layout := spec.Format
+		format = func(t time.Time) interface{} { return t.Format(layout) }
+	}
+	return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+		lo, hi := from, to
+		if within > 0 || spec.From == "" {
+			hi = now.UTC().Truncate(time.Second)
+			lo = hi.Add(-within)
+		}
+		span := hi.Sub(lo)
+		t := lo
+		if span > 0 {
+			t = lo.Add(time.Duration(f.Rand.Int63n(int64(span/time.Second)+1)) * time.Second)
+		}
+		return format(t.UTC()), nil
+	}, nil
+}
+
+func compileArray(path string, spec *ArraySpec) (valueFunc, error) {
+	if spec.Items == nil {
+		return nil, fmt.Errorf("field %s: array needs items", path)
+	}
+	if spec.Min < 0 || spec.Max < spec.Min {
+		return nil, fmt.Errorf("field %s: array needs 0 <= min <= max", path)
+	}
+	item, err := compileField(path+"[]", spec.Items)
+	if err != nil {
+		return nil, err
+	}
+	return func(f *gofakeit.Faker, now time.Time) (interface{}, error) {
+		n := spec.Min + f.Rand.Intn(spec.Max-spec.Min+1)
+		out := make([]interface{}, n)
+		for i := range out {
+			v, err := item(f, now)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}, nil
+}
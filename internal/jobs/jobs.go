@@ -0,0 +1,333 @@
+// Package jobs runs long operations such as document generation in the background of the API server.
+//
+// Every job is stored in Redis as a JSON string under job:<id> (kept for JobTTL), and its ID is added to
+// the jobs sorted set (scored by creation time) so jobs can be listed; a cancellation request is stored
+// under job:<id>:cancel. While a job runs, its state is saved every HeartbeatInterval, and that save
+// doubles as a heartbeat. State therefore survives API restarts: a job whose server went away stops
+// being updated, and once its heartbeat is older than StaleAfter it is reported as interrupted. Because
+// cancellation is stored in Redis, DELETE /jobs/{id} works whichever API instance receives it; the
+// instance running the job picks it up on its next heartbeat.
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/redis/go-redis/v9"
+)
+
+// Status is the lifecycle state of a job.
+type Status string
+
+const (
+	StatusRunning     Status = "running"
+	StatusCompleted   Status = "completed"
+	StatusFailed      Status = "failed"
+	StatusCancelled   Status = "cancelled"
+	StatusInterrupted Status = "interrupted"
+)
+
+// Storage keys and timings.
+const (
+	KeyPrefix = "job:"
+	IndexKey  = "jobs"
+	JobTTL    = 7 * 24 * time.Hour
+)
+
+// HeartbeatInterval is how often a running job saves its progress; a running job whose last save is older
+// than StaleAfter is considered interrupted.
+var (
+	HeartbeatInterval = time.Second
+	StaleAfter        = 30 * time.Second
+)
+
+// ErrNotFound is returned for unknown (or expired) job IDs.
+var ErrNotFound = errors.New("job not found")
+
+// Job is the persisted state of a background job.
+type Job struct {
+	ID              string                 `json:"id"`
+	Type            string                 `json:"type"`
+	Status          Status                 `json:"status"`
+	Params          map[string]interface{} `json:"params,omitempty"`
+	Total           int64                  `json:"total"`
+	Written         int64                  `json:"written"`
+	Failed          int64                  `json:"failed"`
+	Progress        float64                `json:"progress"`
+	DocsPerSec      float64                `json:"docs_per_sec"`
+	Stats           *redisutil.BulkStats   `json:"stats,omitempty"`
+	Error           string                 `json:"error,omitempty"`
+	CancelRequested bool                   `json:"cancel_requested,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+	FinishedAt      *time.Time             `json:"finished_at,omitempty"`
+}
+
+// Finished reports whether the job has reached a final state.
+func (j *Job) Finished() bool {
+	return j.Status != StatusRunning
+}
+
+// RunFunc does the work of a job. It must stop when ctx is cancelled and call report with running totals.
+type RunFunc func(ctx context.Context, report func(written, failed int64)) (redisutil.BulkStats, error)
+
+// Manager starts jobs and tracks the ones running in this process.
+type Manager struct {
+	client       *redis.Client
+	mu           sync.Mutex
+	running      map[string]context.CancelFunc
+	wg           sync.WaitGroup
+	shuttingDown atomic.Bool
+}
+
+// NewManager returns a Manager that stores job state through client.
+func NewManager(client *redis.Client) *Manager {
+	return &Manager{client: client, running: map[string]context.CancelFunc{}}
+}
+
+// Start saves a new running job and runs fn in the background. total is the expected number of documents,
+// used for progress.
+func (m *Manager) Start(jobType string, params map[string]interface{}, total int64, fn RunFunc) (*Job, error) {
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	job := &Job{ID: id, Type: jobType, Status: StatusRunning, Params: params, Total: total, CreatedAt: now, UpdatedAt: now}
+	if err := m.save(context.Background(), job); err != nil {
+		return nil, err
+	}
+	if err := m.client.ZAdd(context.Background(), IndexKey, redis.Z{Score: float64(now.UnixNano()), Member: id}).Err(); err != nil {
+		return nil, err
+	}
+
+	jobCtx, cancel := context.WithCancel(context.Background())
+	m.mu.Lock()
+	m.running[id] = cancel
+	m.mu.Unlock()
+	m.wg.Add(1)
+	go m.run(jobCtx, cancel, *job, fn)
+	return job, nil
+}
+
+func (m *Manager) run(c context.Context, cancel context.CancelFunc, job Job, fn RunFunc) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.running, job.ID)
+		m.mu.Unlock()
+		cancel()
+	}()
+	started := time.Now()
+	var written, failed atomic.Int64
+	var saveMu sync.Mutex
+	heartbeat := func() {
+		saveMu.Lock()
+		defer saveMu.Unlock()
+		job.Written, job.Failed = written.Load(), failed.Load()
+		job.updateRate(started)
+		// Pick up a cancellation requested through another API instance.
+		if n, err := m.client.Exists(context.Background(), cancelKey(job.ID)).Result(); err == nil && n > 0 {
+			job.CancelRequested = true
+			cancel()
+		}
+		if err := m.save(context.Background(), &job); err != nil {
+			slog.Warn("failed to save job state", "job", job.ID, "error", err)
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				heartbeat()
+			}
+		}
+	}()
+
+	stats, err := fn(c, func(w, f int64) {
+		written.Store(w)
+		failed.Store(f)
+	})
+	close(done)
+
+	saveMu.Lock()
+	defer saveMu.Unlock()
+	job.Stats = &stats
+	job.Written, job.Failed = stats.Written, stats.Failed
+	job.updateRate(started)
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	if n, existsErr := m.client.Exists(context.Background(), cancelKey(job.ID)).Result(); existsErr == nil && n > 0 {
+		job.CancelRequested = true
+	}
+	switch {
+	case errors.Is(err, context.Canceled) && job.CancelRequested:
+		job.Status = StatusCancelled
+	case errors.Is(err, context.Canceled) && m.shuttingDown.Load():
+		job.Status = StatusInterrupted
+		job.Error = "API server shut down"
+	case err != nil:
+		job.Status = StatusFailed
+		job.Error = err.Error()
+	case stats.Failed > 0:
+		job.Status = StatusFailed
+		job.Error = fmt.Sprintf("%d documents failed", stats.Failed)
+	default:
+		job.Status = StatusCompleted
+		job.Progress = 1
+	}
+	if err := m.save(context.Background(), &job); err != nil {
+		slog.Error("failed to save final job state", "job", job.ID, "error", err)
+	}
+	slog.Info("job finished", "job", job.ID, "type", job.Type, "status", job.Status, "written", job.Written, "failed", job.Failed)
+}
+
+func (j *Job) updateRate(started time.Time) {
+	j.UpdatedAt = time.Now().UTC()
+	if secs := time.Since(started).Seconds(); secs > 0 {
+		j.DocsPerSec = float64(j.Written) / secs
+	}
+	if j.Total > 0 {
+		j.Progress = float64(j.Written+j.Failed) / float64(j.Total)
+	} else if j.Finished() {
+		j.Progress = 1
+	}
+}
+
+// Get loads a job. A running job whose heartbeat is older than StaleAfter was left behind by an API
+// process that stopped; it is marked interrupted.
+func (m *Manager) Get(c context.Context, id string) (*Job, error) {
+	job, err := m.load(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status == StatusRunning && time.Since(job.UpdatedAt) > StaleAfter {
+		job.Status = StatusInterrupted
+		job.Error = fmt.Sprintf("no heartbeat since %s", job.UpdatedAt.Format(time.RFC3339))
+		if err := m.save(c, job); err != nil {
+			return nil, err
+		}
+	}
+	return job, nil
+}
+
+// List returns the most recent jobs, newest first.
+func (m *Manager) List(c context.Context, limit int) ([]*Job, error) {
+	ids, err := m.client.ZRevRange(c, IndexKey, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		job, err := m.Get(c, id)
+		if errors.Is(err, ErrNotFound) {
+			// Expired: drop it from the index as well.
+			m.client.ZRem(c, IndexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+// Cancel requests cancellation of a running job. A job running in this process stops right away; one
+// running elsewhere stops at its next heartbeat. Finished jobs are returned unchanged.
+func (m *Manager) Cancel(c context.Context, id string) (*Job, error) {
+	job, err := m.Get(c, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Finished() {
+		return job, nil
+	}
+	job.CancelRequested = true
+	if err := m.client.Set(c, cancelKey(id), 1, JobTTL).Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	cancel, ok := m.running[id]
+	m.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return job, nil
+}
+
+// Shutdown stops the jobs running in this process, which record themselves as interrupted, and waits
+// for them to return. It is called when the API server shuts down.
+func (m *Manager) Shutdown() {
+	m.shuttingDown.Store(true)
+	m.mu.Lock()
+	for _, cancel := range m.running {
+		cancel()
+	}
+	m.mu.Unlock()
+	m.wg.Wait()
+}
+
+func (m *Manager) save(c context.Context, job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return m.client.Set(c, KeyPrefix+job.ID, data, JobTTL).Err()
+}
+
+func (m *Manager) load(c context.Context, id string) (*Job, error) {
+	data, err := m.client.Get(c, KeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, fmt.Errorf("decode job %s: %w", id, err)
+	}
+	return &job, nil
+}
+
+// cancelKey is set by Cancel. It is kept apart from the job state so heartbeats cannot overwrite it.
+func cancelKey(id string) string {
+	return KeyPrefix + id + ":cancel"
+}
+
+func newID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+var (
+	singletonManager *Manager
+	singletonOnce    sync.Once
+)
+
+// GetSingletonManager returns the process-wide Manager, created on first use with client.
+func GetSingletonManager(client *redis.Client) *Manager {
+	singletonOnce.Do(func() {
+		singletonManager = NewManager(client)
+	})
+	return singletonManager
+}
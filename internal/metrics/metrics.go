@@ -0,0 +1,155 @@
+// Package metrics exposes the API's telemetry in the Prometheus text format (GET /metrics): per-route
+// request counts, latencies and response sizes, the Redis client's connection pool, and the process
+// gauges internal/monitor collects.
+//
+// Requests are labelled with their route pattern (/jobs/:id rather than /jobs/42) so that the number of
+// series stays bounded; requests that match no route share the route label "unmatched".
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/monitor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+const namespace = "api"
+
+// Registry holds every metric of the API. It has no default Go or process collectors: the monitor
+// collector already covers goroutines, heap and file descriptors.
+var Registry = prometheus.NewRegistry()
+
+var (
+	requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time to handle an HTTP request, by method and route pattern. Streamed responses (/export) are timed until the stream starts.",
+		// Searches take around a millisecond; generation and import requests take seconds.
+		Buckets: []float64{.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+	}, []string{"method", "route"})
+
+	responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "Size of HTTP response bodies, by method and route pattern. Streamed responses are not counted.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 10), // 64B to 16MiB
+	}, []string{"method", "route"})
+)
+
+func init() {
+	Registry.MustRegister(requests, requestDuration, responseSize, monitorCollector{})
+}
+
+// ObserveRequest records a handled request. route is the matched route pattern ("" when none matched);
+// size is ignored for streamed responses, whose body is written after the handler returns.
+func ObserveRequest(method, route string, status int, d time.Duration, size int, streamed bool) {
+	if route == "" {
+		route = "unmatched"
+	}
+	// Fiber's c.Method() points into a request buffer fasthttp reuses, and a series keeps its label
+	// values for good: copy it, or a later request could rewrite the label of an existing series.
+	method = strings.Clone(method)
+	requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	requestDuration.WithLabelValues(method, route).Observe(d.Seconds())
+	if !streamed {
+		responseSize.WithLabelValues(method, route).Observe(float64(size))
+	}
+}
+
+// RegisterRedisPool exports the connection pool statistics of client, the API's singleton client. Only
+// the first client registered is exported; later calls are no-ops.
+func RegisterRedisPool(client *redis.Client) {
+	if err := Registry.Register(redisPoolCollector{client}); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			panic(err)
+		}
+	}
+}
+
+var (
+	poolHits      = poolDesc("hits_total", "Times a free connection was found in the pool.")
+	poolMisses    = poolDesc("misses_total", "Times no free connection was found in the pool.")
+	poolTimeouts  = poolDesc("timeouts_total", "Times waiting for a pool connection timed out.")
+	poolStale     = poolDesc("stale_connections_total", "Stale connections removed from the pool.")
+	poolConns     = prometheus.NewDesc(namespace+"_redis_pool_connections", "Connections in the pool, by state (idle or total).", []string{"state"}, nil)
+	poolWaitCount = poolDesc("waits_total", "Times a caller waited for a pool connection.")
+	poolWaitTime  = poolDesc("wait_seconds_total", "Total time callers waited for a pool connection.")
+)
+
+func poolDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(namespace+"_redis_pool_"+name, help, nil, nil)
+}
+
+// redisPoolCollector reads redis.Client.PoolStats at every scrape.
+type redisPoolCollector struct {
+	client *redis.Client
+}
+
+func (c redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{poolHits, poolMisses, poolTimeouts, poolStale, poolConns, poolWaitCount, poolWaitTime} {
+		ch <- d
+	}
+}
+
+func (c redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(poolHits, prometheus.CounterValue, float64(s.Hits))
+	ch <- prometheus.MustNewConstMetric(poolMisses, prometheus.CounterValue, float64(s.Misses))
+	ch <- prometheus.MustNewConstMetric(poolTimeouts, prometheus.CounterValue, float64(s.Timeouts))
+	ch <- prometheus.MustNewConstMetric(poolStale, prometheus.CounterValue, float64(s.StaleConns))
+	ch <- prometheus.MustNewConstMetric(poolWaitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(poolWaitTime, prometheus.CounterValue, float64(s.WaitDurationNs)/1e9)
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(s.IdleConns), "idle")
+	ch <- prometheus.MustNewConstMetric(poolConns, prometheus.GaugeValue, float64(s.TotalConns), "total")
+}
+
+var (
+	openFDs     = prometheus.NewDesc(namespace+"_open_fds", "Open file descriptors of the process.", nil, nil)
+	tcpSockets  = prometheus.NewDesc(namespace+"_tcp_sockets", "IPv4 TCP sockets of the host (/proc/net/tcp).", nil, nil)
+	pageFaults  = prometheus.NewDesc(namespace+"_page_faults_total", "Page faults of the process, by type (minor or major).", []string{"type"}, nil)
+	goroutines  = prometheus.NewDesc(namespace+"_goroutines", "Goroutines that currently exist.", nil, nil)
+	heapAlloc   = prometheus.NewDesc(namespace+"_heap_alloc_bytes", "Bytes of allocated heap objects.", nil, nil)
+	heapSys     = prometheus.NewDesc(namespace+"_heap_sys_bytes", "Bytes of heap memory obtained from the OS.", nil, nil)
+	heapObjects = prometheus.NewDesc(namespace+"_heap_objects", "Allocated heap objects.", nil, nil)
+	gcCycles    = prometheus.NewDesc(namespace+"_gc_cycles_total", "Completed GC cycles.", nil, nil)
+	gcPause     = prometheus.NewDesc(namespace+"_gc_pause_seconds_total", "Total GC stop-the-world pause time.", nil, nil)
+)
+
+// monitorCollector reads monitor.ReadStats at every scrape. Gauges that need /proc are left out where it
+// does not exist.
+type monitorCollector struct{}
+
+func (monitorCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{openFDs, tcpSockets, pageFaults, goroutines, heapAlloc, heapSys, heapObjects, gcCycles, gcPause} {
+		ch <- d
+	}
+}
+
+func (monitorCollector) Collect(ch chan<- prometheus.Metric) {
+	s := monitor.ReadStats()
+	if s.OpenFDs >= 0 {
+		ch <- prometheus.MustNewConstMetric(openFDs, prometheus.GaugeValue, float64(s.OpenFDs))
+	}
+	if s.TCPSockets >= 0 {
+		ch <- prometheus.MustNewConstMetric(tcpSockets, prometheus.GaugeValue, float64(s.TCPSockets))
+	}
+	if s.MinorPageFaults >= 0 {
+		ch <- prometheus.MustNewConstMetric(pageFaults, prometheus.CounterValue, float64(s.MinorPageFaults), "minor")
+		ch <- prometheus.MustNewConstMetric(pageFaults, prometheus.CounterValue, float64(s.MajorPageFaults), "major")
+	}
+	ch <- prometheus.MustNewConstMetric(goroutines, prometheus.GaugeValue, float64(s.Goroutines))
+	ch <- prometheus.MustNewConstMetric(heapAlloc, prometheus.GaugeValue, float64(s.HeapAllocBytes))
+	ch <- prometheus.MustNewConstMetric(heapSys, prometheus.GaugeValue, float64(s.HeapSysBytes))
+	ch <- prometheus.MustNewConstMetric(heapObjects, prometheus.GaugeValue, float64(s.HeapObjects))
+	ch <- prometheus.MustNewConstMetric(gcCycles, prometheus.CounterValue, float64(s.GCCycles))
+	ch <- prometheus.MustNewConstMetric(gcPause, prometheus.CounterValue, float64(s.GCPauseTotalNs)/1e9)
+}
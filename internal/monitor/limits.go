@@ -74,6 +74,49 @@ func logLimits() {
 
 type pageFaults struct{ Minor, Major uint64 }
 
+// Stats are the process gauges logLimits reports, for export as metrics. The /proc-based ones (OpenFDs,
+// TCPSockets, page faults) are -1 where /proc is not available, such as on macOS.
+type Stats struct {
+	OpenFDs         int
+	TCPSockets      int
+	MinorPageFaults int64
+	MajorPageFaults int64
+	Goroutines      int
+	HeapAllocBytes  uint64
+	HeapSysBytes    uint64
+	HeapObjects     uint64
+	GCCycles        uint32
+	GCPauseTotalNs  uint64
+}
+
+// ReadStats collects the current Stats.
+func ReadStats() Stats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	s := Stats{
+		OpenFDs:         -1,
+		TCPSockets:      -1,
+		MinorPageFaults: -1,
+		MajorPageFaults: -1,
+		Goroutines:      runtime.NumGoroutine(),
+		HeapAllocBytes:  m.HeapAlloc,
+		HeapSysBytes:    m.HeapSys,
+		HeapObjects:     m.HeapObjects,
+		GCCycles:        m.NumGC,
+		GCPauseTotalNs:  m.PauseTotalNs,
+	}
+	if n, err := countOpenFDs(); err == nil {
+		s.OpenFDs = n
+	}
+	if n, err := countTCPSockets(); err == nil {
+		s.TCPSockets = n
+	}
+	if pf, err := getPageFaults(); err == nil {
+		s.MinorPageFaults, s.MajorPageFaults = int64(pf.Minor), int64(pf.Major)
+	}
+	return s
+}
+
 func getUptimeSeconds() int64 {
 	if data, err := ioutil.ReadFile("/proc/uptime"); err == nil {
 		parts := strings.Fields(string(data))
@@ -97,22 +140,24 @@ func countOpenFDs() (int, error) {
 
 func countTCPSockets() (int, error) {
 	// Conta linhas em /proc/net/tcp
-	if data, err := ioutil.ReadFile("/proc/net/tcp"); err == nil {
-		return len(strings.Split(strings.TrimSpace(string(data)), "\n")) - 1, nil
+	data, err := ioutil.ReadFile("/proc/net/tcp")
+	if err != nil {
+		return -1, err
 	}
-	return -1, nil
+	return len(strings.Split(strings.TrimSpace(string(data)), "\n")) - 1, nil
 }
 
 func getPageFaults() (pageFaults, error) {
 	pf := pageFaults{}
-	if data, err := ioutil.ReadFile("/proc/self/stat"); err == nil {
-		fields := strings.Fields(string(data))
-		if len(fields) > 12 {
-			// minflt: 10, majflt: 12
-			pf.Minor, _ = strconv.ParseUint(fields[9], 10, 64)
-			pf.Major, _ = strconv.ParseUint(fields[11], 10, 64)
-		}
-		return pf, nil
+	data, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return pf, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) > 12 {
+		// minflt: 10, majflt: 12
+		pf.Minor, _ = strconv.ParseUint(fields[9], 10, 64)
+		pf.Major, _ = strconv.ParseUint(fields[11], 10, 64)
 	}
 	return pf, nil
 }
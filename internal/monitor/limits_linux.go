@@ -4,12 +4,14 @@ package monitor
 
 import (
 	"log/slog"
-	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
+// logNprocLimit logs RLIMIT_NPROC, which the syscall package only defines on BSD-derived systems.
 func logNprocLimit() {
-	var pLimit syscall.Rlimit
-	if err := syscall.Getrlimit(syscall.RLIMIT_NPROC, &pLimit); err == nil {
+	var pLimit unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NPROC, &pLimit); err == nil {
 		slog.Info("[MONITOR] RLIMIT_NPROC", "cur", pLimit.Cur, "max", pLimit.Max)
 	}
 }
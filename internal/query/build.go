@@ -0,0 +1,73 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// Build turns field=value filters (API query parameters, CLI key=value arguments) into a RediSearch query.
+// Every filter is an exact match compiled like a q= term, so values are escaped for their field type:
+// @f:{tag} for TAG, @f:[min max] for NUMERIC (ranges as min..max) and @f:"text" for TEXT.
+// Fields that are not in the index are rejected. No filters yields "*".
+func Build(idx *schema.Index, identifiers map[string]string) (string, error) {
+	if err := idx.ValidateFields(identifiers); err != nil {
+		return "", err
+	}
+	keys := make([]string, 0, len(identifiers))
+	for k := range identifiers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		t := &Term{Field: k, Value: identifiers[k]}
+		if field, _ := idx.Field(k); field.Type == schema.TypeNumeric {
+			t.Kind = Range
+		}
+		s, err := compileTerm(t, idx)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, s)
+	}
+	return Intersect(parts...), nil
+}
+
+// BuildWithQuery is Build with an optional q= boolean query (see Translate) ANDed on.
+func BuildWithQuery(idx *schema.Index, identifiers map[string]string, q string) (string, error) {
+	base, err := Build(idx, identifiers)
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(q) == "" {
+		return base, nil
+	}
+	compiled, err := Translate(q, idx)
+	if err != nil {
+		return "", fmt.Errorf("q: %w", err)
+	}
+	return Intersect(base, compiled), nil
+}
+
+// NumericRange returns a NUMERIC range clause for bounds produced by the schema helpers
+// (e.g. schema.TimeRangeBounds).
+func NumericRange(field string, min string, max string) string {
+	return fmt.Sprintf("@%s:[%s %s]", field, min, max)
+}
+
+// Intersect ANDs already compiled clauses, skipping empty ones and "*". No clauses yields "*".
+func Intersect(clauses ...string) string {
+	parts := make([]string, 0, len(clauses))
+	for _, c := range clauses {
+		if c != "" && c != "*" {
+			parts = append(parts, c)
+		}
+	}
+	if len(parts) == 0 {
+		return "*"
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,147 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// MinPrefixLength matches the RediSearch MINPREFIX default; shorter prefixes are rejected by the server.
+const MinPrefixLength = 2
+
+// Translate parses a query, validates it against idx and returns the equivalent RediSearch query.
+func Translate(input string, idx *schema.Index) (string, error) {
+	n, err := Parse(input)
+	if err != nil {
+		return "", err
+	}
+	return Compile(n, idx)
+}
+
+// Validate checks every term of the AST against the index schema: the field must exist and the kind of
+// match must make sense for its type (prefix on TAG/TEXT, fuzzy on TEXT, ranges on NUMERIC).
+func Validate(n Node, idx *schema.Index) error {
+	_, err := Compile(n, idx)
+	return err
+}
+
+// Compile validates the AST against idx and renders it as a RediSearch query string.
+func Compile(n Node, idx *schema.Index) (string, error) {
+	switch n := n.(type) {
+	case *Or:
+		parts := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			s, err := Compile(c, idx)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "(" + strings.Join(parts, " | ") + ")", nil
+	case *And:
+		parts := make([]string, len(n.Children))
+		for i, c := range n.Children {
+			s, err := Compile(c, idx)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return "(" + strings.Join(parts, " ") + ")", nil
+	case *Not:
+		s, err := Compile(n.Child, idx)
+		if err != nil {
+			return "", err
+		}
+		return "-" + s, nil
+	case *Term:
+		return compileTerm(n, idx)
+	default:
+		return "", fmt.Errorf("unsupported query node %T", n)
+	}
+}
+
+func compileTerm(t *Term, idx *schema.Index) (string, error) {
+	field, ok := idx.Field(t.Field)
+	if !ok {
+		return "", fmt.Errorf("unknown field %q for index %s (allowed: %s)", t.Field, idx.Name, strings.Join(idx.Aliases(), ", "))
+	}
+	if t.Value == "" {
+		return "", fmt.Errorf("missing value for %s", t.Field)
+	}
+	switch field.Type {
+	case schema.TypeNumeric:
+		switch t.Kind {
+		case Exact, Range:
+		default:
+			return "", fmt.Errorf("field %s is NUMERIC: use a number, min..max or >, >=, <, <=", t.Field)
+		}
+		min, max := t.Min, t.Max
+		if min == "" && max == "" {
+			var err error
+			min, max, err = schema.ParseNumericRange(t.Value)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %w", t.Field, err)
+			}
+		}
+		if t.MinExclusive {
+			min = "(" + min
+		}
+		if t.MaxExclusive {
+			max = "(" + max
+		}
+		return fmt.Sprintf("@%s:[%s %s]", t.Field, min, max), nil
+	case schema.TypeTag:
+		switch t.Kind {
+		case Exact:
+			return fmt.Sprintf("@%s:{%s}", t.Field, escapeValue(t.Value, false)), nil
+		case Prefix:
+			if err := checkPrefix(t); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("@%s:{%s*}", t.Field, escapeValue(t.Value, false)), nil
+		case Fuzzy:
+			return "", fmt.Errorf("field %s is a TAG: fuzzy matching is only supported on TEXT fields", t.Field)
+		default:
+			return "", fmt.Errorf("field %s is a TAG: ranges are only supported on NUMERIC fields", t.Field)
+		}
+	default:
+		switch t.Kind {
+		case Exact:
+			return fmt.Sprintf("@%s:\"%s\"", t.Field, escapeValue(t.Value, true)), nil
+		case Prefix:
+			if err := checkPrefix(t); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("@%s:%s*", t.Field, escapeValue(t.Value, false)), nil
+		case Fuzzy:
+			pad := strings.Repeat("%", t.Distance)
+			return fmt.Sprintf("@%s:%s%s%s", t.Field, pad, escapeValue(t.Value, false), pad), nil
+		default:
+			return "", fmt.Errorf("field %s is TEXT: ranges are only supported on NUMERIC fields", t.Field)
+		}
+	}
+}
+
+func checkPrefix(t *Term) error {
+	if len([]rune(t.Value)) < MinPrefixLength {
+		return fmt.Errorf("field %s: prefix must be at least %d characters", t.Field, MinPrefixLength)
+	}
+	return nil
+}
+
+// escapeValue backslash-escapes everything but letters, digits and underscores so punctuation in user
+// input (e.g. the @ and . of an email) is matched literally instead of being read as query syntax.
+// Spaces are kept inside quoted TEXT phrases, where they separate the words of the phrase.
+func escapeValue(val string, keepSpaces bool) string {
+	var b strings.Builder
+	for _, r := range val {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && !(keepSpaces && r == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
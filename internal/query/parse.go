@@ -0,0 +1,364 @@
+// Package query implements the small boolean query language accepted by the search endpoints (q=)
+// and the search commands (--q), for example:
+//
+//	email:foo@x.com OR phone:555* -deleted:1
+//	(visitor_id:abc OR call_id:call_abcde) event_type:page_view
+//	name:jon~ confidenceScore:>=0.8
+//
+// A query is parsed into an AST, validated against the index schema and compiled into RediSearch
+// syntax with every value escaped for its field type, so user input can never add query operators.
+//
+// Grammar (AND binds tighter than OR, juxtaposition means AND):
+//
+//	or      = and { ("OR" | "|") and }
+//	and     = unary { ["AND"] unary }
+//	unary   = ("-" | "NOT") unary | primary
+//	primary = "(" or ")" | field ":" value
+//	value   = "quoted string" | word | word* | word~ | word~N | min..max | >n | >=n | <n | <=n
+package query
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MaxQueryLength and MaxDepth bound the input so hostile queries cannot exhaust the parser.
+const (
+	MaxQueryLength = 4096
+	MaxDepth       = 32
+)
+
+// Node is a node of the query AST: *Or, *And, *Not or *Term.
+type Node interface {
+	node()
+}
+
+// Or matches documents matching any child.
+type Or struct{ Children []Node }
+
+// And matches documents matching every child.
+type And struct{ Children []Node }
+
+// Not matches documents that do not match Child.
+type Not struct{ Child Node }
+
+// TermKind says how a term's value is matched.
+type TermKind int
+
+const (
+	Exact TermKind = iota
+	Prefix
+	Fuzzy
+	Range
+)
+
+// Term is a single field:value condition. For Fuzzy terms Distance is the Levenshtein distance (1-3);
+// for Range terms Min and Max hold the NUMERIC bounds (inclusive unless MinExclusive/MaxExclusive).
+type Term struct {
+	Field        string
+	Value        string
+	Kind         TermKind
+	Distance     int
+	Min, Max     string
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+func (*Or) node()   {}
+func (*And) node()  {}
+func (*Not) node()  {}
+func (*Term) node() {}
+
+// Parse parses a query string into an AST. It does not look at the schema; see Validate.
+func Parse(input string) (Node, error) {
+	if len(input) > MaxQueryLength {
+		return nil, fmt.Errorf("query too long (max %d bytes)", MaxQueryLength)
+	}
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("empty query")
+	}
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return n, nil
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokLParen
+	tokRParen
+	tokOr
+	tokAnd
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits the input on whitespace and parentheses. Double quotes group a value that may
+// contain either; a leading "-" on a word is a NOT.
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{tokLParen, "("})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{tokRParen, ")"})
+			i++
+		case c == '-' && i+1 < len(input) && input[i+1] != ' ':
+			tokens = append(tokens, token{tokNot, "-"})
+			i++
+		default:
+			start := i
+			inQuotes := false
+			for i < len(input) {
+				c := input[i]
+				if inQuotes {
+					if c == '\\' && i+1 < len(input) {
+						i += 2
+						continue
+					}
+					if c == '"' {
+						inQuotes = false
+					}
+					i++
+					continue
+				}
+				if c == '"' {
+					inQuotes = true
+					i++
+					continue
+				}
+				if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '(' || c == ')' {
+					break
+				}
+				i++
+			}
+			if inQuotes {
+				return nil, fmt.Errorf("unterminated quote in %q", input[start:])
+			}
+			word := input[start:i]
+			switch word {
+			case "OR", "|":
+				tokens = append(tokens, token{tokOr, word})
+			case "AND":
+				tokens = append(tokens, token{tokAnd, word})
+			case "NOT":
+				tokens = append(tokens, token{tokNot, word})
+			default:
+				tokens = append(tokens, token{tokWord, word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) parseOr(depth int) (Node, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("query nested too deeply (max %d)", MaxDepth)
+	}
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			break
+		}
+		p.pos++
+		next, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &Or{Children: children}, nil
+}
+
+func (p *parser) parseAnd(depth int) (Node, error) {
+	first, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	children := []Node{first}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokOr || t.kind == tokRParen {
+			break
+		}
+		if t.kind == tokAnd {
+			p.pos++
+		}
+		next, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, next)
+	}
+	if len(children) == 1 {
+		return first, nil
+	}
+	return &And{Children: children}, nil
+}
+
+func (p *parser) parseUnary(depth int) (Node, error) {
+	if depth > MaxDepth {
+		return nil, fmt.Errorf("query nested too deeply (max %d)", MaxDepth)
+	}
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("unexpected end of query")
+	}
+	switch t.kind {
+	case tokNot:
+		p.pos++
+		child, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Child: child}, nil
+	case tokLParen:
+		p.pos++
+		n, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := p.peek(); !ok || t.kind != tokRParen {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+		return n, nil
+	case tokWord:
+		p.pos++
+		return parseTerm(t.text)
+	default:
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+}
+
+// parseTerm parses field:value. Range syntax is only recognised here; whether it is allowed depends on
+// the field type and is checked by Validate.
+func parseTerm(word string) (*Term, error) {
+	field, value, ok := strings.Cut(word, ":")
+	if !ok || field == "" {
+		return nil, fmt.Errorf("expected field:value, got %q", word)
+	}
+	if value == "" {
+		return nil, fmt.Errorf("missing value for %s", field)
+	}
+	t := &Term{Field: field}
+	if strings.HasPrefix(value, `"`) {
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return nil, fmt.Errorf("malformed quoted value for %s", field)
+		}
+		unquoted, err := unquote(value[1 : len(value)-1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", field, err)
+		}
+		t.Value = unquoted
+		return t, nil
+	}
+	if strings.Contains(value, `"`) {
+		return nil, fmt.Errorf("unexpected quote in value for %s", field)
+	}
+	switch {
+	case strings.HasPrefix(value, ">=") || strings.HasPrefix(value, "<=") || strings.HasPrefix(value, ">") || strings.HasPrefix(value, "<"):
+		t.Kind = Range
+		op := value[:1]
+		num := value[1:]
+		inclusive := strings.HasPrefix(num, "=")
+		num = strings.TrimPrefix(num, "=")
+		f, err := strconv.ParseFloat(num, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%s: invalid number %q", field, num)
+		}
+		num = strconv.FormatFloat(f, 'f', -1, 64)
+		if op == ">" {
+			t.Min, t.Max, t.MinExclusive = num, "+inf", !inclusive
+		} else {
+			t.Min, t.Max, t.MaxExclusive = "-inf", num, !inclusive
+		}
+		t.Value = value
+	case strings.Contains(value, ".."):
+		t.Kind = Range
+		t.Value = value
+	case strings.HasSuffix(value, "*"):
+		t.Kind = Prefix
+		t.Value = strings.TrimSuffix(value, "*")
+		if t.Value == "" || strings.Contains(t.Value, "*") {
+			return nil, fmt.Errorf("%s: invalid prefix %q", field, value)
+		}
+	case strings.Contains(value, "~"):
+		stem, dist, _ := strings.Cut(value, "~")
+		t.Kind = Fuzzy
+		t.Value = stem
+		t.Distance = 1
+		if dist != "" {
+			n, err := strconv.Atoi(dist)
+			if err != nil || n < 1 || n > 3 {
+				return nil, fmt.Errorf("%s: fuzzy distance must be 1-3, got %q", field, dist)
+			}
+			t.Distance = n
+		}
+		if stem == "" {
+			return nil, fmt.Errorf("%s: empty fuzzy term", field)
+		}
+	default:
+		t.Value = value
+	}
+	return t, nil
+}
+
+func unquote(s string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			if i+1 >= len(s) {
+				return "", fmt.Errorf("dangling escape")
+			}
+			i++
+		} else if s[i] == '"' {
+			return "", fmt.Errorf("unescaped quote")
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), nil
+}
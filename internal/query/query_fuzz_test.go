@@ -0,0 +1,169 @@
+package query
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// fuzzIndex covers every field type, since the default schema has no TEXT fields.
+var fuzzIndex = &schema.Index{
+	Name:   "fuzzIdx",
+	Prefix: "fuzz:",
+	Fields: []schema.Field{
+		{Path: "$.email", Alias: "email", Type: schema.TypeTag},
+		{Path: "$.name", Alias: "name", Type: schema.TypeText},
+		{Path: "$.score", Alias: "score", Type: schema.TypeNumeric},
+	},
+}
+
+var hostileSeeds = []string{
+	`email:foo@x.com OR name:jon* -score:1`,
+	`(email:"a b" | name:jon~2) AND score:>=0.8`,
+	`email:x} | @score:[0 +inf] {`,
+	`name:"a\" | @email:{*}"`,
+	`name:foo) | (@email:{x`,
+	`email:\\`,
+	`score:1..2] | [3`,
+	`score:>0x1p-2`,
+	`name:%%%a%%%`,
+	`-(-(-(email:a)))`,
+	`email:"" OR ()`,
+	strings.Repeat("(", 100) + "email:a" + strings.Repeat(")", 100),
+}
+
+// FuzzTranslate feeds arbitrary q= strings through the parser and compiler. Whatever the input, it must
+// either be rejected or compile to a structurally sound query that only references schema fields.
+func FuzzTranslate(f *testing.F) {
+	for _, s := range hostileSeeds {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		out, err := Translate(input, fuzzIndex)
+		if err != nil {
+			return
+		}
+		checkCompiled(t, input, out)
+	})
+}
+
+// FuzzBuild checks that a field=value filter always matches its value literally: the value must come
+// back unchanged after removing the escapes, and nothing in it may be read as query syntax.
+func FuzzBuild(f *testing.F) {
+	for _, s := range hostileSeeds {
+		f.Add("email", s)
+		f.Add("name", s)
+		f.Add("score", s)
+	}
+	f.Fuzz(func(t *testing.T, field string, value string) {
+		out, err := Build(fuzzIndex, map[string]string{field: value})
+		if _, known := fuzzIndex.Field(field); !known {
+			if err == nil {
+				t.Fatalf("unknown field %q accepted: %s", field, out)
+			}
+			return
+		}
+		if err != nil {
+			return
+		}
+		checkCompiled(t, value, out)
+		if field == "score" || !utf8.ValidString(value) {
+			return
+		}
+		open, close := "{", "}"
+		if field == "name" {
+			open, close = `"`, `"`
+		}
+		prefix := "@" + field + ":" + open
+		if !strings.HasPrefix(out, prefix) || !strings.HasSuffix(out, close) {
+			t.Fatalf("%q: unexpected shape %s", value, out)
+		}
+		if got := unescape(out[len(prefix) : len(out)-len(close)]); got != value {
+			t.Fatalf("%q: value changed to %q (%s)", value, got, out)
+		}
+	})
+}
+
+// checkCompiled walks a compiled query and fails if brackets or quotes are unbalanced, if a tag or
+// phrase contains an unescaped operator, if a range holds anything but numbers, or if it references a
+// field that is not in the schema.
+func checkCompiled(t *testing.T, input, out string) {
+	t.Helper()
+	var stack []byte
+	inTag, inPhrase, inRange := false, false, false
+	for i := 0; i < len(out); i++ {
+		c := out[i]
+		if c == '\\' {
+			if i+1 >= len(out) {
+				t.Fatalf("%q: dangling escape in %s", input, out)
+			}
+			i++
+			continue
+		}
+		switch {
+		case inTag:
+			if c == '}' {
+				inTag = false
+			} else if !isWordByte(c) && !(c == '*' && i+1 < len(out) && out[i+1] == '}') {
+				t.Fatalf("%q: unescaped %q inside tag in %s", input, c, out)
+			}
+		case inPhrase:
+			if c == '"' {
+				inPhrase = false
+			} else if !isWordByte(c) && c != ' ' {
+				t.Fatalf("%q: unescaped %q inside phrase in %s", input, c, out)
+			}
+		case inRange:
+			if c == ']' {
+				inRange = false
+			} else if !strings.ContainsRune("0123456789.-+inf( e", rune(c)) {
+				t.Fatalf("%q: unexpected %q inside range in %s", input, c, out)
+			}
+		default:
+			switch c {
+			case '{':
+				inTag = true
+			case '"':
+				inPhrase = true
+			case '[':
+				inRange = true
+			case '(':
+				stack = append(stack, c)
+			case ')':
+				if len(stack) == 0 {
+					t.Fatalf("%q: unbalanced ) in %s", input, out)
+				}
+				stack = stack[:len(stack)-1]
+			case '@':
+				end := strings.IndexByte(out[i:], ':')
+				if end < 0 {
+					t.Fatalf("%q: field without : in %s", input, out)
+				}
+				if _, ok := fuzzIndex.Field(out[i+1 : i+end]); !ok {
+					t.Fatalf("%q: unknown field %q in %s", input, out[i+1:i+end], out)
+				}
+				i += end
+			}
+		}
+	}
+	if inTag || inPhrase || inRange || len(stack) > 0 {
+		t.Fatalf("%q: unterminated tag, phrase, range or group in %s", input, out)
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c >= 0x80 || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,363 @@
+package redisutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Bulk ingest.
+//
+// BulkGenerate writes count generated documents using a pool of workers. Each worker takes a range of
+// BatchSize indexes, generates and marshals those documents, and sends them in one round trip: a pipeline
+// of JSON.SET commands, or a single JSON.MSET when UseMSET is set (RedisJSON 2.6+). Batches that fail with
+// a transient error (network, timeout, LOADING, BUSY, TRYAGAIN) are retried with exponential backoff;
+// documents rejected by the server are counted as failed and reported per batch.
+
+// Defaults and limits for BulkOptions.
+const (
+	DefaultBulkBatchSize  = 500
+	MaxBulkBatchSize      = 10000
+	MaxBulkWorkers        = 256
+	DefaultBulkMaxRetries = 3
+	MaxBulkRetries        = 10
+	maxBulkErrors         = 100
+
+	// maxRetryDelay caps the exponential backoff between retries of a batch.
+	maxRetryDelay = 5 * time.Second
+)
+
+// BulkOptions configures BulkGenerate. Zero values select the defaults (DefaultBulkBatchSize,
+// runtime.NumCPU() workers, DefaultBulkMaxRetries).
+type BulkOptions struct {
+	BatchSize int
+	Workers   int
+	// MaxRetries is how many times a batch's transient failures are retried; 0 turns retries off and nil
+	// selects DefaultBulkMaxRetries.
+	MaxRetries *int
+	UseMSET    bool
+	// OnProgress, if set, is called after every batch with the running totals. It is called from the
+	// worker goroutines, so it must be safe for concurrent use.
+	OnProgress func(written, failed int64)
+}
+
+// Validate fills in defaults and rejects out-of-range values.
+func (o *BulkOptions) Validate() error {
+	if o.BatchSize == 0 {
+		o.BatchSize = DefaultBulkBatchSize
+	}
+	if o.Workers == 0 {
+		o.Workers = runtime.NumCPU()
+	}
+	if o.MaxRetries == nil {
+		retries := DefaultBulkMaxRetries
+		o.MaxRetries = &retries
+	}
+	if o.BatchSize < 1 || o.BatchSize > MaxBulkBatchSize {
+		return fmt.Errorf("batch size must be between 1 and %d", MaxBulkBatchSize)
+	}
+	if o.Workers < 1 || o.Workers > MaxBulkWorkers {
+		return fmt.Errorf("workers must be between 1 and %d", MaxBulkWorkers)
+	}
+	if *o.MaxRetries < 0 || *o.MaxRetries > MaxBulkRetries {
+		return fmt.Errorf("max retries must be between 0 and %d", MaxBulkRetries)
+	}
+	return nil
+}
+
+// BatchError describes a batch in which some documents could not be written.
+type BatchError struct {
+	Batch    int    `json:"batch"`
+	FirstKey string `json:"first_key"`
+	Failed   int    `json:"failed"`
+	Error    string `json:"error"`
+}
+
+// BulkStats summarises a bulk write. Bytes is the size of the marshalled documents; Errors holds at
+// most 100 batch errors. Keys is filled in by callers that allocate keys with a KeyAllocator, one range
+// per key prefix written.
+type BulkStats struct {
+	Written    int64         `json:"written"`
+	Failed     int64         `json:"failed"`
+	Batches    int64         `json:"batches"`
+	Retries    int64         `json:"retries"`
+	Bytes      int64         `json:"bytes"`
+	Elapsed    time.Duration `json:"-"`
+	ElapsedMs  int64         `json:"elapsed_ms"`
+	DocsPerSec float64       `json:"docs_per_sec"`
+	Errors     []BatchError  `json:"errors,omitempty"`
+	Keys       []KeyRange    `json:"keys,omitempty"`
+}
+
+// Add merges the stats of a bulk write that ran after this one, such as the second phase of a
+// generation that writes two datasets.
+func (s *BulkStats) Add(o BulkStats) {
+	s.Written += o.Written
+	s.Failed += o.Failed
+	s.Batches += o.Batches
+	s.Retries += o.Retries
+	s.Bytes += o.Bytes
+	s.Elapsed += o.Elapsed
+	s.ElapsedMs = s.Elapsed.Milliseconds()
+	if secs := s.Elapsed.Seconds(); secs > 0 {
+		s.DocsPerSec = float64(s.Written) / secs
+	}
+	for _, e := range o.Errors {
+		if len(s.Errors) < maxBulkErrors {
+			s.Errors = append(s.Errors, e)
+		}
+	}
+	s.Keys = append(s.Keys, o.Keys...)
+}
+
+// bulkRun collects the outcome of the batches of a bulk write, recorded concurrently by its workers.
+type bulkRun struct {
+	start                                    time.Time
+	written, failed, batches, retries, bytes atomic.Int64
+	mu                                       sync.Mutex
+	errs                                     []BatchError
+	onProgress                               func(written, failed int64)
+}
+
+func newBulkRun(opts BulkOptions) *bulkRun {
+	return &bulkRun{start: time.Now(), onProgress: opts.OnProgress}
+}
+
+// nextBatch numbers a batch, from 1.
+func (r *bulkRun) nextBatch() int {
+	return int(r.batches.Add(1))
+}
+
+// record adds the outcome of batch: written items succeeded and failed did not, with err as the reason
+// and firstKey as the batch's first key. It reports progress.
+func (r *bulkRun) record(batch, written, failed, retries int, firstKey string, err error) {
+	r.written.Add(int64(written))
+	r.failed.Add(int64(failed))
+	r.retries.Add(int64(retries))
+	if failed > 0 && err != nil {
+		r.mu.Lock()
+		if len(r.errs) < maxBulkErrors {
+			r.errs = append(r.errs, BatchError{Batch: batch, FirstKey: firstKey, Failed: failed, Error: err.Error()})
+		}
+		r.mu.Unlock()
+	}
+	if r.onProgress != nil {
+		r.onProgress(r.written.Load(), r.failed.Load())
+	}
+}
+
+// stats returns the totals, once the workers are done.
+func (r *bulkRun) stats() BulkStats {
+	stats := BulkStats{
+		Written: r.written.Load(),
+		Failed:  r.failed.Load(),
+		Batches: r.batches.Load(),
+		Retries: r.retries.Load(),
+		Bytes:   r.bytes.Load(),
+		Elapsed: time.Since(r.start),
+		Errors:  r.errs,
+	}
+	stats.ElapsedMs = stats.Elapsed.Milliseconds()
+	if secs := stats.Elapsed.Seconds(); secs > 0 {
+		stats.DocsPerSec = float64(stats.Written) / secs
+	}
+	return stats
+}
+
+// runWorkers runs work on n goroutines and waits for all of them to return. Each worker typically ranges
+// over a channel of batches, keeping its buffers from one batch to the next.
+func runWorkers(n int, work func()) {
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work()
+		}()
+	}
+	wg.Wait()
+}
+
+// batchStarts returns a channel of the first indexes of the batches of size covering 0..count-1. It stops
+// early, and is closed, when ctx is done.
+func batchStarts(c context.Context, count, size int) <-chan int {
+	starts := make(chan int)
+	go func() {
+		defer close(starts)
+		for s := 0; s < count; s += size {
+			select {
+			case starts <- s:
+			case <-c.Done():
+				return
+			}
+		}
+	}()
+	return starts
+}
+
+// bulkDoc is a marshalled document ready to be sent.
+type bulkDoc struct {
+	key  string
+	data string
+}
+
+// BulkGenerate writes documents 0..count-1, produced by gen, in pipelined batches. gen is called
+// concurrently from the workers. When ctx is cancelled no new batches are started and the returned
+// stats cover the batches written so far, together with ctx.Err().
+func BulkGenerate(c context.Context, client *redis.Client, count int, opts BulkOptions, gen func(i int) (key string, doc interface{}, err error)) (BulkStats, error) {
+	if err := opts.Validate(); err != nil {
+		return BulkStats{}, err
+	}
+	run := newBulkRun(opts)
+	starts := batchStarts(c, count, opts.BatchSize)
+	runWorkers(opts.Workers, func() {
+		docs := make([]bulkDoc, 0, opts.BatchSize)
+		for s := range starts {
+			docs = docs[:0]
+			end := min(s+opts.BatchSize, count)
+			genFailed := 0
+			var genErr error
+			for i := s; i < end; i++ {
+				key, doc, err := gen(i)
+				if err == nil {
+					var data []byte
+					data, err = json.Marshal(doc)
+					if err == nil {
+						docs = append(docs, bulkDoc{key: key, data: string(data)})
+						run.bytes.Add(int64(len(data)))
+						continue
+					}
+				}
+				genFailed++
+				genErr = err
+			}
+			n, r, err := writeBatch(c, client, docs, opts)
+			if err == nil {
+				err = genErr
+			}
+			first := ""
+			if len(docs) > 0 {
+				first = docs[0].key
+			}
+			run.record(run.nextBatch(), n, genFailed+len(docs)-n, r, first, err)
+		}
+	})
+	return run.stats(), c.Err()
+}
+
+// writeBatch sends one batch, as a JSON.MSET or a pipeline of JSON.SET, retrying the documents that
+// failed with a transient error. It returns how many documents were written, how many retries were made
+// and the last error seen.
+func writeBatch(c context.Context, client *redis.Client, docs []bulkDoc, opts BulkOptions) (int, int, error) {
+	return retryBatch(c, docs, *opts.MaxRetries, func(pending []bulkDoc) []error {
+		if opts.UseMSET {
+			args := make([]interface{}, 0, 1+3*len(pending))
+			args = append(args, "JSON.MSET")
+			for _, d := range pending {
+				args = append(args, d.key, "$", d.data)
+			}
+			// JSON.MSET is atomic: every document shares its outcome.
+			errs := make([]error, len(pending))
+			if err := client.Do(c, args...).Err(); err != nil {
+				for i := range errs {
+					errs[i] = err
+				}
+			}
+			return errs
+		}
+		cmds := make([][]interface{}, len(pending))
+		for i, d := range pending {
+			cmds[i] = []interface{}{"JSON.SET", d.key, "$", d.data}
+		}
+		return pipelineErrors(c, client, cmds)
+	})
+}
+
+// retryDelay is the backoff before retry attempt: 100ms, 200ms, 400ms... up to maxRetryDelay.
+func retryDelay(attempt int) time.Duration {
+	if attempt >= 7 {
+		return maxRetryDelay
+	}
+	return min(50*time.Millisecond<<attempt, maxRetryDelay)
+}
+
+// retryBatch sends items with send, which returns the error of each item (nil once written), and sends
+// the ones that failed with a transient error again, with exponential backoff, up to maxRetries times. It
+// returns how many items were written, how many retries were made and the last error seen.
+func retryBatch[T any](c context.Context, items []T, maxRetries int, send func(pending []T) []error) (int, int, error) {
+	pending := items
+	written, retries := 0, 0
+	var lastErr error
+	for attempt := 0; len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			if attempt > maxRetries {
+				break
+			}
+			retries++
+			select {
+			case <-time.After(retryDelay(attempt)):
+			case <-c.Done():
+				return written, retries, c.Err()
+			}
+		}
+		var retry []T
+		for i, err := range send(pending) {
+			if err != nil {
+				lastErr = err
+				if isTransient(err) {
+					retry = append(retry, pending[i])
+				}
+				continue
+			}
+			written++
+		}
+		pending = retry
+	}
+	return written, retries, lastErr
+}
+
+// pipelineErrors sends cmds in one pipeline and returns the error of each.
+func pipelineErrors(c context.Context, client *redis.Client, cmds [][]interface{}) []error {
+	pipe := client.Pipeline()
+	results := make([]*redis.Cmd, len(cmds))
+	for i, args := range cmds {
+		results[i] = pipe.Do(c, args...)
+	}
+	_, execErr := pipe.Exec(c)
+	errs := make([]error, len(cmds))
+	for i, res := range results {
+		errs[i] = res.Err()
+		if errs[i] == nil && res.Val() == nil && execErr != nil {
+			// Connection-level failures are only reported by Exec, not on the commands.
+			errs[i] = execErr
+		}
+	}
+	return errs
+}
+
+// isTransient reports whether a write error is worth retrying: anything that is not an error reply from
+// the server (network errors, timeouts), or a reply asking the client to try again later.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var redisErr redis.Error
+	if !errors.As(err, &redisErr) {
+		return true
+	}
+	msg := redisErr.Error()
+	for _, prefix := range []string{"LOADING", "BUSY", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
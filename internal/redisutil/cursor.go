@@ -0,0 +1,105 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Deep pagination.
+//
+// FT.SEARCH with a large LIMIT offset has to walk and discard every earlier match, so each page gets
+// slower. AggregateWithCursor instead opens a server-side FT.AGGREGATE cursor once, and ReadCursor
+// streams the following pages from it at constant cost. Cursors are dropped by the server after
+// CursorMaxIdle milliseconds of inactivity; the API answers 410 for a page read from a dropped cursor.
+
+// CursorMaxIdle is the idle timeout, in milliseconds, passed to FT.AGGREGATE ... MAXIDLE.
+var CursorMaxIdle = 300000
+
+// aggregateArgs returns the FT.AGGREGATE arguments that sort the matches of query and skip the first
+// opts.Offset, up to the LIMIT count. The order is total, by opts.SortBy and then by key, so pages read by
+// different commands (AggregatePage, then AggregateWithCursor) neither repeat nor miss documents. Only the
+// key is loaded before sorting; the returned fields are loaded for the rows LIMIT lets through.
+func aggregateArgs(index string, query string, opts SearchOptions, count int64) []interface{} {
+	args := []interface{}{"FT.AGGREGATE", index, query, "LOAD", "1", "@__key"}
+	if opts.SortBy != "" {
+		args = append(args, "SORTBY", "4", "@"+opts.SortBy, sortDir(opts.SortDesc), "@__key", "ASC")
+	} else {
+		args = append(args, "SORTBY", "2", "@__key", "ASC")
+	}
+	args = append(args, "LIMIT", opts.Offset, count)
+	return append(args, returnArgs("LOAD", opts.Fields)...)
+}
+
+// AggregatePage returns the page opts.Offset, opts.Limit of query in the order AggregateWithCursor
+// continues it from, with the number of matches counted by FT.SEARCH in the same round trip. It gives up
+// when c is done.
+func AggregatePage(c context.Context, client *redis.Client, index string, query string, opts SearchOptions) (SearchResult, error) {
+	pipe := client.Pipeline()
+	count := pipe.Do(c, "FT.SEARCH", index, query, "NOCONTENT", "LIMIT", 0, 0)
+	args := append(aggregateArgs(index, query, opts, int64(opts.Limit)), dialectArgs(opts.Fields)...)
+	rows := pipe.Do(c, args...)
+	if _, err := pipe.Exec(c); err != nil {
+		return SearchResult{}, err
+	}
+	counted, err := parseSearchResults(count.Val(), true, nil)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	// The count at the head of an FT.AGGREGATE reply is not the number of matches.
+	page, err := parseSearchResults(rows.Val(), false, opts.Fields)
+	page.Total = counted.Total
+	return page, err
+}
+
+// AggregateWithCursor runs query through FT.AGGREGATE WITHCURSOR, skipping opts.Offset matches and
+// returning the first opts.Limit documents. total is the number of matches, as reported by a
+// previous AggregatePage, and bounds the LIMIT step.
+func AggregateWithCursor(client *redis.Client, index string, query string, opts SearchOptions, total int64) (SearchResult, error) {
+	remaining := total - int64(opts.Offset)
+	if remaining < 1 {
+		remaining = 1
+	}
+	args := aggregateArgs(index, query, opts, remaining)
+	args = append(args, "WITHCURSOR", "COUNT", opts.Limit, "MAXIDLE", CursorMaxIdle)
+	args = append(args, dialectArgs(opts.Fields)...)
+	res, err := client.Do(ctx, args...).Result()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	page, err := parseCursorReply(res, opts.Fields)
+	page.Total = total
+	return page, err
+}
+
+// ReadCursor reads the next count documents from a cursor opened by AggregateWithCursor.
+// fields must match the ones the cursor was opened with.
+func ReadCursor(client *redis.Client, index string, cursorID int64, count int, fields []string) (SearchResult, error) {
+	res, err := client.Do(ctx, "FT.CURSOR", "READ", index, cursorID, "COUNT", count).Result()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return parseCursorReply(res, fields)
+}
+
+// DeleteCursor releases a cursor that will not be read to the end.
+func DeleteCursor(client *redis.Client, index string, cursorID int64) error {
+	return client.Do(ctx, "FT.CURSOR", "DEL", index, cursorID).Err()
+}
+
+// parseCursorReply splits a [results, cursor_id] reply. The total of an aggregate batch is not
+// meaningful, so it is left to the caller.
+func parseCursorReply(res interface{}, fields []string) (SearchResult, error) {
+	pair, ok := res.([]interface{})
+	if !ok || len(pair) != 2 {
+		return SearchResult{}, fmt.Errorf("unexpected cursor response: %T", res)
+	}
+	page, err := parseSearchResults(pair[0], false, fields)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	page.Total = 0
+	page.CursorID = replyInt(pair[1])
+	return page, nil
+}
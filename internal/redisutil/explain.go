@@ -0,0 +1,220 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ExplainNode is one node of the execution plan printed by FT.EXPLAIN, e.g. INTERSECT, UNION, NOT,
+// TAG:@email or a NUMERIC range. Leaves are the terms the iterators will look up.
+type ExplainNode struct {
+	Op       string        `json:"op"`
+	Children []ExplainNode `json:"children,omitempty"`
+}
+
+// ExplainSearch runs FT.EXPLAIN for a query and returns the parsed plan along with the raw text lines.
+func ExplainSearch(client *redis.Client, index string, query string) ([]ExplainNode, []string, error) {
+	res, err := client.Do(ctx, "FT.EXPLAIN", index, query).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	text := replyString(res)
+	if list, ok := res.([]interface{}); ok {
+		text = strings.Join(replyStrings(list), "\n")
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	plan, err := parseExplain(lines)
+	return plan, lines, err
+}
+
+// parseExplain turns the indented FT.EXPLAIN text into a tree. A line ending in "{" opens a node, a line
+// holding only "}" closes it, and anything else (including "NUMERIC {1 <= @f <= 2}") is a leaf.
+func parseExplain(lines []string) ([]ExplainNode, error) {
+	root := &ExplainNode{}
+	stack := []*ExplainNode{root}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		parent := stack[len(stack)-1]
+		switch {
+		case line == "}":
+			if len(stack) == 1 {
+				return nil, fmt.Errorf("unbalanced FT.EXPLAIN output")
+			}
+			stack = stack[:len(stack)-1]
+		case strings.HasSuffix(line, "{"):
+			parent.Children = append(parent.Children, ExplainNode{Op: strings.TrimSpace(strings.TrimSuffix(line, "{"))})
+			stack = append(stack, &parent.Children[len(parent.Children)-1])
+		default:
+			parent.Children = append(parent.Children, ExplainNode{Op: line})
+		}
+	}
+	if len(stack) != 1 {
+		return nil, fmt.Errorf("unbalanced FT.EXPLAIN output")
+	}
+	return root.Children, nil
+}
+
+// ProfileResult is the outcome of FT.PROFILE: the number of matches and the profile converted to JSON
+// (total/parsing/pipeline times, the iterator tree with counters and timings, result processors).
+type ProfileResult struct {
+	Total   int64       `json:"total"`
+	Profile interface{} `json:"profile"`
+}
+
+// ProfileSearch runs the same search as SearchFTSWithOptions under FT.PROFILE.
+func ProfileSearch(client *redis.Client, index string, query string, opts SearchOptions) (ProfileResult, error) {
+	args := append([]interface{}{"FT.PROFILE", index, "SEARCH", "QUERY", query}, searchArgs(opts)...)
+	res, err := client.Do(ctx, args...).Result()
+	if err != nil {
+		return ProfileResult{}, err
+	}
+	var results, profile interface{}
+	switch r := res.(type) {
+	case []interface{}:
+		// RESP2: [search reply, profile]
+		if len(r) != 2 {
+			return ProfileResult{}, fmt.Errorf("unexpected FT.PROFILE response length: %d", len(r))
+		}
+		results, profile = r[0], r[1]
+	default:
+		// RESP3: {"Results": search reply, "Profile": profile}
+		m, ok := replyMap(res)
+		if !ok {
+			return ProfileResult{}, fmt.Errorf("unexpected FT.PROFILE response type: %T", res)
+		}
+		for k, v := range m {
+			switch strings.ToLower(k) {
+			case "results":
+				results = v
+			case "profile":
+				profile = v
+			}
+		}
+	}
+	page, err := parseSearchResults(results, true, opts.Fields)
+	if err != nil {
+		return ProfileResult{}, err
+	}
+	return ProfileResult{Total: page.Total, Profile: profileValue("", profile)}, nil
+}
+
+// profileValue converts an FT.PROFILE reply into plain JSON values. RESP3 maps are used as they are;
+// RESP2 encodes objects either as flat [key, value, ...] lists (iterators, result processors) or as
+// lists of [label, value...] entries (the top-level profile), and lists child iterators as every array
+// that follows the "Child iterators" key. Timings and counters are turned into numbers.
+func profileValue(key string, v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}, map[string]interface{}:
+		m, _ := replyMap(t)
+		out := make(map[string]interface{}, len(m))
+		for k, val := range m {
+			out[k] = profileValue(k, val)
+		}
+		return out
+	case []interface{}:
+		if m, ok := profilePairs(t); ok {
+			return m
+		}
+		if m, ok := profileLabelled(t); ok {
+			return m
+		}
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = profileValue(key, item)
+		}
+		return out
+	case string, []byte:
+		s := replyString(t)
+		if isProfileNumber(key) {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return f
+			}
+		}
+		return s
+	default:
+		return t
+	}
+}
+
+// profilePairs reads a RESP2 [key, value, ...] list such as ["Type", "TAG", "Counter", 3, ...].
+func profilePairs(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) < 2 {
+		return nil, false
+	}
+	out := map[string]interface{}{}
+	for i := 0; i < len(list); {
+		k, ok := list[i].(string)
+		if !ok || isNumber(k) {
+			return nil, false
+		}
+		i++
+		if k == "Child iterators" {
+			var children []interface{}
+			for i < len(list) {
+				child, ok := list[i].([]interface{})
+				if !ok {
+					break
+				}
+				children = append(children, profileValue(k, child))
+				i++
+			}
+			out[k] = children
+			continue
+		}
+		if i >= len(list) {
+			return nil, false
+		}
+		out[k] = profileValue(k, list[i])
+		i++
+	}
+	return out, true
+}
+
+// profileLabelled reads a RESP2 list of [label, value...] entries with distinct labels.
+func profileLabelled(list []interface{}) (map[string]interface{}, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	out := map[string]interface{}{}
+	for _, item := range list {
+		entry, ok := item.([]interface{})
+		if !ok || len(entry) < 2 {
+			return nil, false
+		}
+		label, ok := entry[0].(string)
+		if !ok || isNumber(label) {
+			return nil, false
+		}
+		if _, dup := out[label]; dup {
+			return nil, false
+		}
+		if len(entry) == 2 {
+			out[label] = profileValue(label, entry[1])
+			continue
+		}
+		rest := make([]interface{}, 0, len(entry)-1)
+		for _, v := range entry[1:] {
+			rest = append(rest, profileValue(label, v))
+		}
+		out[label] = rest
+	}
+	return out, true
+}
+
+func isProfileNumber(key string) bool {
+	k := strings.ToLower(key)
+	return strings.Contains(k, "time") || k == "counter" || k == "size"
+}
+
+func isNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
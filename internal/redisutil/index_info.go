@@ -1,41 +1,232 @@
 package redisutil
 
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+)
 
 // IndexFieldInfo holds information about a single indexed field
 type IndexFieldInfo struct {
-	Path   string `json:"path"`
-	Alias  string `json:"alias"`
-	Type   string `json:"type"`
+	Path  string   `json:"path"`
+	Alias string   `json:"alias"`
+	Type  string   `json:"type"`
+	Flags []string `json:"flags,omitempty"`
+}
+
+// IndexMemory holds the memory figures FT.INFO reports for an index, in megabytes
+type IndexMemory struct {
+	InvertedSzMB         float64 `json:"inverted_sz_mb"`
+	OffsetVectorsSzMB    float64 `json:"offset_vectors_sz_mb"`
+	DocTableSizeMB       float64 `json:"doc_table_size_mb"`
+	SortableValuesSizeMB float64 `json:"sortable_values_size_mb"`
+	KeyTableSizeMB       float64 `json:"key_table_size_mb"`
+	VectorIndexSzMB      float64 `json:"vector_index_sz_mb"`
+	TotalIndexMemorySzMB float64 `json:"total_index_memory_sz_mb"`
 }
 
-// IndexInfo holds information about a RediSearch index and its fields
+// IndexInfo holds information about a RediSearch index and its fields, as reported live by FT.INFO
 type IndexInfo struct {
-	Name   string           `json:"name"`
-	Fields []IndexFieldInfo `json:"fields"`
-}
-
-// GetIndexesAndFields lists RediSearch indexes and their fields from static knowledge (for healthz)
-func GetIndexesAndFields() ([]IndexInfo, error) {
-	// These are hardcoded to match the FT.CREATE statements in CreateCustomerIndex and CreateEventIndex
-	return []IndexInfo{
-		{
-			Name: "customerIdx",
-			Fields: []IndexFieldInfo{
-				{Path: "$.primaryIdentifiers.email", Alias: "email", Type: "TEXT"},
-				{Path: "$.primaryIdentifiers.phone", Alias: "phone", Type: "TEXT"},
-				{Path: "$.primaryIdentifiers.visitor_id", Alias: "visitor_id", Type: "TEXT"},
-			},
-		},
-		{
-			Name: "eventIdx",
-			Fields: []IndexFieldInfo{
-				{Path: "$.identifiers.visitor_id", Alias: "visitor_id", Type: "TEXT"},
-				{Path: "$.identifiers.call_id", Alias: "call_id", Type: "TEXT"},
-				{Path: "$.identifiers.chat_id", Alias: "chat_id", Type: "TEXT"},
-				{Path: "$.identifiers.external_id", Alias: "external_id", Type: "TEXT"},
-				{Path: "$.identifiers.lead_id", Alias: "lead_id", Type: "TEXT"},
-				{Path: "$.identifiers.tickets_id", Alias: "tickets_id", Type: "TEXT"},
-			},
+	Name             string           `json:"name"`
+	IndexName        string           `json:"index_name,omitempty"`
+	Exists           bool             `json:"exists"`
+	Prefixes         []string         `json:"prefixes,omitempty"`
+	Fields           []IndexFieldInfo `json:"fields"`
+	NumDocs          int64            `json:"num_docs"`
+	NumRecords       int64            `json:"num_records"`
+	Indexing         bool             `json:"indexing"`
+	PercentIndexed   float64          `json:"percent_indexed"`
+	IndexingFailures int64            `json:"hash_indexing_failures"`
+	LastError        string           `json:"last_indexing_error,omitempty"`
+	LastErrorKey     string           `json:"last_indexing_error_key,omitempty"`
+	Memory           IndexMemory      `json:"memory"`
+	SchemaDrift      []string         `json:"schema_drift,omitempty"`
+	Error            string           `json:"error,omitempty"`
+}
+
+// GetIndexInfo runs FT.INFO on an index or alias and parses the reply (RESP2 or RESP3).
+// IndexName holds the physical index that answered, which differs from name when name is an alias.
+func GetIndexInfo(client *redis.Client, name string) (IndexInfo, error) {
+	raw, err := ftInfo(client, name)
+	if err != nil {
+		return IndexInfo{Name: name}, err
+	}
+	return parseIndexInfo(name, raw), nil
+}
+
+// GetIndexesAndFields reports every index in the schema registry as it exists on the server, for healthz.
+// Missing indexes are returned with Exists=false, and differences between the live attributes and the
+// registry are listed in SchemaDrift.
+func GetIndexesAndFields(client *redis.Client) ([]IndexInfo, error) {
+	reg := schema.Get()
+	out := make([]IndexInfo, 0, len(reg.Indexes))
+	for i := range reg.Indexes {
+		idx := &reg.Indexes[i]
+		info, err := GetIndexInfo(client, idx.Name)
+		if err != nil {
+			if !IsUnknownIndex(err) {
+				return nil, err
+			}
+			info.Error = err.Error()
+		}
+		info.SchemaDrift = schemaDrift(idx, info)
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// ListIndexInfo returns FT.INFO for every physical index on the server (FT._LIST), sorted by name.
+func ListIndexInfo(client *redis.Client) ([]IndexInfo, error) {
+	names, err := ListIndexes(client)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	out := make([]IndexInfo, 0, len(names))
+	for _, name := range names {
+		info, err := GetIndexInfo(client, name)
+		if err != nil {
+			info.Error = err.Error()
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// ftInfo runs FT.INFO and returns the reply as a string-keyed map, whatever the protocol version.
+func ftInfo(client *redis.Client, name string) (map[string]interface{}, error) {
+	res, err := client.Do(ctx, "FT.INFO", name).Result()
+	if err != nil {
+		return nil, err
+	}
+	info, ok := replyMap(res)
+	if !ok {
+		return nil, fmt.Errorf("unexpected FT.INFO response type: %T", res)
+	}
+	return info, nil
+}
+
+func parseIndexInfo(name string, raw map[string]interface{}) IndexInfo {
+	info := IndexInfo{
+		Name:             name,
+		IndexName:        replyString(raw["index_name"]),
+		Exists:           true,
+		NumDocs:          replyInt(raw["num_docs"]),
+		NumRecords:       replyInt(raw["num_records"]),
+		Indexing:         replyInt(raw["indexing"]) != 0,
+		PercentIndexed:   replyFloat(raw["percent_indexed"]),
+		IndexingFailures: replyInt(raw["hash_indexing_failures"]),
+		Memory: IndexMemory{
+			InvertedSzMB:         replyFloat(raw["inverted_sz_mb"]),
+			OffsetVectorsSzMB:    replyFloat(raw["offset_vectors_sz_mb"]),
+			DocTableSizeMB:       replyFloat(raw["doc_table_size_mb"]),
+			SortableValuesSizeMB: replyFloat(raw["sortable_values_size_mb"]),
+			KeyTableSizeMB:       replyFloat(raw["key_table_size_mb"]),
+			VectorIndexSzMB:      replyFloat(raw["vector_index_sz_mb"]),
+			TotalIndexMemorySzMB: replyFloat(raw["total_index_memory_sz_mb"]),
 		},
-	}, nil
+	}
+	// "Index Errors" is only reported by RediSearch 2.8+; "N/A" means no document has failed yet.
+	if errs, ok := replyMap(raw["Index Errors"]); ok {
+		if last := replyString(errs["last indexing error"]); last != "N/A" {
+			info.LastError = last
+			info.LastErrorKey = replyString(errs["last indexing error key"])
+		}
+	}
+	if def, ok := replyMap(raw["index_definition"]); ok {
+		info.Prefixes = replyStrings(def["prefixes"])
+	}
+	attrs, _ := raw["attributes"].([]interface{})
+	for _, a := range attrs {
+		info.Fields = append(info.Fields, parseAttribute(a))
+	}
+	return info
+}
+
+// attributeValueKeys are the FT.INFO attribute properties that carry a value. In RESP2 every other token
+// (SORTABLE, NOSTEM, ...) is a bare flag, which is why attributes cannot simply be read as pairs.
+var attributeValueKeys = map[string]bool{
+	"identifier": true, "attribute": true, "type": true,
+	"WEIGHT": true, "SEPARATOR": true, "PHONETIC": true, "flags": true,
+}
+
+func parseAttribute(v interface{}) IndexFieldInfo {
+	var f IndexFieldInfo
+	set := func(key string, val interface{}) {
+		switch key {
+		case "identifier":
+			f.Path = replyString(val)
+		case "attribute":
+			f.Alias = replyString(val)
+		case "type":
+			f.Type = replyString(val)
+		case "flags":
+			f.Flags = append(f.Flags, replyStrings(val)...)
+		}
+	}
+	switch t := v.(type) {
+	case []interface{}:
+		for i := 0; i < len(t); i++ {
+			key := replyString(t[i])
+			if attributeValueKeys[key] && i+1 < len(t) {
+				set(key, t[i+1])
+				i++
+				continue
+			}
+			f.Flags = append(f.Flags, key)
+		}
+	default:
+		if m, ok := replyMap(v); ok {
+			for key, val := range m {
+				set(key, val)
+			}
+		}
+	}
+	return f
+}
+
+// schemaDrift lists the differences between the registry definition and the live index.
+func schemaDrift(idx *schema.Index, info IndexInfo) []string {
+	if !info.Exists {
+		return []string{"index does not exist on the server"}
+	}
+	var drift []string
+	if len(info.Prefixes) > 0 && !contains(info.Prefixes, idx.Prefix) {
+		drift = append(drift, fmt.Sprintf("prefix: schema %q, server %s", idx.Prefix, strings.Join(info.Prefixes, ",")))
+	}
+	live := map[string]IndexFieldInfo{}
+	for _, f := range info.Fields {
+		live[f.Alias] = f
+	}
+	for _, f := range idx.Fields {
+		got, ok := live[f.Alias]
+		switch {
+		case !ok:
+			drift = append(drift, fmt.Sprintf("field %s: missing on server", f.Alias))
+		case got.Path != f.Path:
+			drift = append(drift, fmt.Sprintf("field %s: schema path %s, server %s", f.Alias, f.Path, got.Path))
+		case got.Type != string(f.Type):
+			drift = append(drift, fmt.Sprintf("field %s: schema type %s, server %s", f.Alias, f.Type, got.Type))
+		case f.Sortable && !contains(got.Flags, "SORTABLE"):
+			drift = append(drift, fmt.Sprintf("field %s: not SORTABLE on server", f.Alias))
+		}
+		delete(live, f.Alias)
+	}
+	for alias := range live {
+		drift = append(drift, fmt.Sprintf("field %s: not in schema", alias))
+	}
+	sort.Strings(drift)
+	return drift
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
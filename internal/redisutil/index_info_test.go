@@ -0,0 +1,137 @@
+package redisutil
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+)
+
+// infoIndex is the registry definition the recorded FT.INFO replies below are checked against.
+var infoIndex = &schema.Index{
+	Name:   "eventIdx",
+	Prefix: "event:",
+	Fields: []schema.Field{
+		{Path: "$.event_id", Alias: "event_id", Type: schema.TypeTag},
+		{Path: "$.timestamp_epoch", Alias: "timestamp", Type: schema.TypeNumeric, Sortable: true},
+	},
+}
+
+// TestParseIndexInfo decodes FT.INFO eventIdx as go-redis returns it over RESP2 (flat arrays, numbers
+// mostly as strings, bare attribute flags) and RESP3 (maps, doubles, a flags list per attribute).
+func TestParseIndexInfo(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply interface{}
+		want  IndexInfo
+		drift []string
+	}{
+		{
+			name: "resp2",
+			reply: []interface{}{
+				"index_name", "eventIdx_v2",
+				"index_options", []interface{}{},
+				"index_definition", []interface{}{
+					"key_type", "JSON",
+					"prefixes", []interface{}{"event:"},
+					"default_score", "1",
+				},
+				"attributes", []interface{}{
+					[]interface{}{"identifier", "$.event_id", "attribute", "event_id", "type", "TAG", "SEPARATOR", ","},
+					[]interface{}{"identifier", "$.timestamp_epoch", "attribute", "timestamp", "type", "NUMERIC", "SORTABLE", "UNF"},
+				},
+				"num_docs", "1200",
+				"max_doc_id", "1200",
+				"num_records", "3600",
+				"inverted_sz_mb", "0.25",
+				"total_index_memory_sz_mb", "1.5",
+				"hash_indexing_failures", "2",
+				"indexing", "0",
+				"percent_indexed", "1",
+				"Index Errors", []interface{}{
+					"indexing failures", int64(2),
+					"last indexing error", "Invalid JSON value",
+					"last indexing error key", "event:00000042",
+				},
+			},
+			want: IndexInfo{
+				Name:             "eventIdx",
+				IndexName:        "eventIdx_v2",
+				Exists:           true,
+				Prefixes:         []string{"event:"},
+				NumDocs:          1200,
+				NumRecords:       3600,
+				PercentIndexed:   1,
+				IndexingFailures: 2,
+				LastError:        "Invalid JSON value",
+				LastErrorKey:     "event:00000042",
+				Memory:           IndexMemory{InvertedSzMB: 0.25, TotalIndexMemorySzMB: 1.5},
+				Fields: []IndexFieldInfo{
+					{Path: "$.event_id", Alias: "event_id", Type: "TAG"},
+					{Path: "$.timestamp_epoch", Alias: "timestamp", Type: "NUMERIC", Flags: []string{"SORTABLE", "UNF"}},
+				},
+			},
+		},
+		{
+			name: "resp3",
+			reply: map[interface{}]interface{}{
+				"index_name":    "eventIdx_v3",
+				"index_options": []interface{}{},
+				"index_definition": map[interface{}]interface{}{
+					"key_type":      "JSON",
+					"prefixes":      []interface{}{"event:"},
+					"default_score": float64(1),
+				},
+				"attributes": []interface{}{
+					map[interface{}]interface{}{"identifier": "$.event_id", "attribute": "event_id", "type": "TAG", "SEPARATOR": ",", "flags": []interface{}{}},
+					map[interface{}]interface{}{"identifier": "$.timestamp_epoch", "attribute": "timestamp", "type": "NUMERIC", "flags": []interface{}{}},
+					map[interface{}]interface{}{"identifier": "$.identifiers.lead_id", "attribute": "lead_id", "type": "TAG", "SEPARATOR": ",", "flags": []interface{}{}},
+				},
+				"num_docs":                 int64(800),
+				"num_records":              int64(2400),
+				"inverted_sz_mb":           float64(0.125),
+				"total_index_memory_sz_mb": float64(0.75),
+				"hash_indexing_failures":   int64(0),
+				"indexing":                 int64(1),
+				"percent_indexed":          float64(0.5),
+				"Index Errors": map[interface{}]interface{}{
+					"indexing failures":       int64(0),
+					"last indexing error":     "N/A",
+					"last indexing error key": "N/A",
+				},
+			},
+			want: IndexInfo{
+				Name:           "eventIdx",
+				IndexName:      "eventIdx_v3",
+				Exists:         true,
+				Prefixes:       []string{"event:"},
+				NumDocs:        800,
+				NumRecords:     2400,
+				Indexing:       true,
+				PercentIndexed: 0.5,
+				Memory:         IndexMemory{InvertedSzMB: 0.125, TotalIndexMemorySzMB: 0.75},
+				Fields: []IndexFieldInfo{
+					{Path: "$.event_id", Alias: "event_id", Type: "TAG"},
+					{Path: "$.timestamp_epoch", Alias: "timestamp", Type: "NUMERIC"},
+					{Path: "$.identifiers.lead_id", Alias: "lead_id", Type: "TAG"},
+				},
+			},
+			drift: []string{"field lead_id: not in schema", "field timestamp: not SORTABLE on server"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, ok := replyMap(tt.reply)
+			if !ok {
+				t.Fatalf("replyMap(%T) failed", tt.reply)
+			}
+			got := parseIndexInfo("eventIdx", raw)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseIndexInfo:\n got %+v\nwant %+v", got, tt.want)
+			}
+			if drift := schemaDrift(infoIndex, got); !reflect.DeepEqual(drift, tt.drift) {
+				t.Errorf("schemaDrift = %q, want %q", drift, tt.drift)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Key allocation for generated documents.
+//
+// A KeyAllocator names the documents of one generation run. KeySequential (the default) produces
+// prefix0..prefixN-1 and overwrites the documents of earlier runs; the other strategies grow the dataset:
+// KeyCounter reserves a block of N numbers with an atomic INCRBY on keyseq:<prefix> so concurrent runs get
+// disjoint ranges, KeyULID and KeyUUID generate random IDs (ULIDs sort by creation time), and KeyDocID
+// uses the document's own ID (customerId, event_id).
+
+// KeyStrategy selects how generated documents are keyed.
+type KeyStrategy string
+
+const (
+	KeySequential KeyStrategy = "sequential"
+	KeyCounter    KeyStrategy = "counter"
+	KeyULID       KeyStrategy = "ulid"
+	KeyUUID       KeyStrategy = "uuid"
+	KeyDocID      KeyStrategy = "docid"
+)
+
+// KeyStrategies lists the valid strategies, in the order shown in help texts.
+var KeyStrategies = []KeyStrategy{KeySequential, KeyCounter, KeyULID, KeyUUID, KeyDocID}
+
+// ParseKeyStrategy validates a strategy name; "" selects KeySequential.
+func ParseKeyStrategy(s string) (KeyStrategy, error) {
+	if s == "" {
+		return KeySequential, nil
+	}
+	for _, k := range KeyStrategies {
+		if string(k) == s {
+			return k, nil
+		}
+	}
+	names := make([]string, len(KeyStrategies))
+	for i, k := range KeyStrategies {
+		names[i] = string(k)
+	}
+	return "", fmt.Errorf("unknown key strategy %q (valid: %s)", s, strings.Join(names, ", "))
+}
+
+// KeyRange reports the keys a generation run produced. For the numeric strategies (sequential, counter)
+// Start and End are the first and last number; for the others First and Last are the lowest and highest
+// key in lexicographic order (for ULIDs, the oldest and newest).
+type KeyRange struct {
+	Strategy KeyStrategy `json:"strategy"`
+	Prefix   string      `json:"prefix"`
+	Count    int64       `json:"count"`
+	First    string      `json:"first,omitempty"`
+	Last     string      `json:"last,omitempty"`
+	Start    *int64      `json:"start,omitempty"`
+	End      *int64      `json:"end,omitempty"`
+}
+
+// String formats the range for CLI output, e.g. "customer:1000..customer:1999 (counter, 1000 keys)".
+func (r KeyRange) String() string {
+	if r.Count == 0 {
+		return fmt.Sprintf("no keys (%s)", r.Strategy)
+	}
+	return fmt.Sprintf("%s..%s (%s, %d keys)", r.First, r.Last, r.Strategy, r.Count)
+}
+
+// KeyAllocator hands out the keys of one generation run. Key is safe for concurrent use.
+type KeyAllocator struct {
+	strategy    KeyStrategy
+	prefix      string
+	base        int64
+	mu          sync.Mutex
+	count       int64
+	first, last string
+	low, high   int64
+}
+
+// CounterKey is the INCRBY counter used by KeyCounter for a key prefix, e.g. keyseq:customer.
+func CounterKey(prefix string) string {
+	return "keyseq:" + strings.TrimSuffix(prefix, ":")
+}
+
+// NewKeyAllocator returns an allocator for count documents under prefix. For KeyCounter it reserves the
+// block of numbers right away.
+func NewKeyAllocator(c context.Context, client *redis.Client, strategy KeyStrategy, prefix string, count int) (*KeyAllocator, error) {
+	a := &KeyAllocator{strategy: strategy, prefix: prefix}
+	if strategy == KeyCounter && count > 0 {
+		end, err := client.IncrBy(c, CounterKey(prefix), int64(count)).Result()
+		if err != nil {
+			return nil, fmt.Errorf("reserve keys: %w", err)
+		}
+		a.base = end - int64(count)
+	}
+	return a, nil
+}
+
+// Key returns the key of document i. docID is the document's own ID, required by KeyDocID.
+func (a *KeyAllocator) Key(i int, docID string) (string, error) {
+	var key string
+	n := a.base + int64(i)
+	switch a.strategy {
+	case KeySequential, KeyCounter:
+		key = a.prefix + strconv.FormatInt(n, 10)
+	case KeyULID:
+		key = a.prefix + newULID(time.Now())
+	case KeyUUID:
+		key = a.prefix + uuid.NewString()
+	case KeyDocID:
+		if docID == "" {
+			return "", fmt.Errorf("document %d has no ID", i)
+		}
+		key = a.prefix + docID
+	default:
+		return "", fmt.Errorf("unknown key strategy %q", a.strategy)
+	}
+	a.mu.Lock()
+	a.count++
+	if a.strategy == KeySequential || a.strategy == KeyCounter {
+		if a.count == 1 || n < a.low {
+			a.low = n
+		}
+		if n > a.high {
+			a.high = n
+		}
+	} else {
+		if a.first == "" || key < a.first {
+			a.first = key
+		}
+		if key > a.last {
+			a.last = key
+		}
+	}
+	a.mu.Unlock()
+	return key, nil
+}
+
+// Range returns the keys allocated so far.
+func (a *KeyAllocator) Range() KeyRange {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	r := KeyRange{Strategy: a.strategy, Prefix: a.prefix, Count: a.count, First: a.first, Last: a.last}
+	if (a.strategy == KeySequential || a.strategy == KeyCounter) && a.count > 0 {
+		start, end := a.low, a.high
+		r.Start, r.End = &start, &end
+		r.First = a.prefix + strconv.FormatInt(start, 10)
+		r.Last = a.prefix + strconv.FormatInt(end, 10)
+	}
+	return r
+}
+
+// crockford is the base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// newULID returns a 26-character ULID: a 48-bit millisecond timestamp followed by 80 random bits.
+func newULID(t time.Time) string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(t.UnixMilli())<<16)
+	if _, err := rand.Read(b[6:]); err != nil {
+		panic("ulid: " + err.Error())
+	}
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	// 128 bits as 26 groups of 5 bits, most significant first (the first group holds only 3 bits).
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
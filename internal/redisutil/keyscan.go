@@ -0,0 +1,157 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key selection.
+//
+// EachKeyPage lists the keys of JSON documents page by page, without holding them all in memory: by key
+// prefix with SCAN MATCH prefix* TYPE ReJSON-RL, or by a query over an index with an FT.AGGREGATE cursor
+// that loads @__key. It is shared by the NDJSON export and the sampler.
+
+// KeySelection selects documents by key prefix, by a query over an index, or both. Query (RediSearch
+// syntax, default "*") needs Index; with an index and a prefix, only the matches whose key starts with
+// the prefix are selected.
+type KeySelection struct {
+	Prefix string
+	Index  string
+	Query  string
+}
+
+// Validate rejects selections without a prefix or an index.
+func (s KeySelection) Validate() error {
+	if s.Prefix == "" && s.Index == "" {
+		return fmt.Errorf("a key prefix or an index is required")
+	}
+	if s.Query != "" && s.Index == "" {
+		return fmt.Errorf("a query needs an index")
+	}
+	return nil
+}
+
+// EachKeyPage calls fn with successive pages of about size selected keys, starting at position: a SCAN
+// cursor for a prefix selection, or the number of matches to skip for an index selection. fn also gets
+// the position after the page and whether it is the last one. Pages may be empty. As with SCAN, a key
+// present for the whole listing is listed at least once, and twice if the keyspace is resized meanwhile.
+// EachKeyPage stops at the first error from fn or when ctx is cancelled.
+func EachKeyPage(c context.Context, client *redis.Client, sel KeySelection, size int, position uint64, fn func(keys []string, position uint64, done bool) error) error {
+	if err := sel.Validate(); err != nil {
+		return err
+	}
+	if sel.Index != "" {
+		return eachQueryPage(c, client, sel, size, position, fn)
+	}
+	pattern := scanPattern(sel.Prefix)
+	for {
+		if err := c.Err(); err != nil {
+			return err
+		}
+		keys, next, err := client.ScanType(c, position, pattern, int64(size), "ReJSON-RL").Result()
+		if err != nil {
+			return err
+		}
+		if err := fn(keys, next, next == 0); err != nil || next == 0 {
+			return err
+		}
+		position = next
+	}
+}
+
+func eachQueryPage(c context.Context, client *redis.Client, sel KeySelection, size int, skip uint64, fn func(keys []string, position uint64, done bool) error) error {
+	query := sel.Query
+	if query == "" {
+		query = "*"
+	}
+	total, _, err := SearchKeys(client, sel.Index, query, 0)
+	if err != nil {
+		return err
+	}
+	if skip >= uint64(total) {
+		return fn(nil, skip, true)
+	}
+	args := []interface{}{"FT.AGGREGATE", sel.Index, query, "LOAD", 1, "@__key"}
+	if skip > 0 {
+		args = append(args, "LIMIT", skip, uint64(total)-skip)
+	}
+	args = append(args, "WITHCURSOR", "COUNT", size, "MAXIDLE", CursorMaxIdle)
+	res, err := client.Do(c, args...).Result()
+	position := skip
+	for err == nil {
+		pair, ok := res.([]interface{})
+		if !ok || len(pair) != 2 {
+			return fmt.Errorf("unexpected cursor response: %T", res)
+		}
+		keys := aggregateKeys(pair[0])
+		cursorID := replyInt(pair[1])
+		position += uint64(len(keys))
+		if sel.Prefix != "" {
+			matching := keys[:0]
+			for _, key := range keys {
+				if strings.HasPrefix(key, sel.Prefix) {
+					matching = append(matching, key)
+				}
+			}
+			keys = matching
+		}
+		if err := fn(keys, position, cursorID == 0); err != nil {
+			if cursorID != 0 {
+				_ = DeleteCursor(client, sel.Index, cursorID)
+			}
+			return err
+		}
+		if cursorID == 0 {
+			return nil
+		}
+		if err := c.Err(); err != nil {
+			_ = DeleteCursor(client, sel.Index, cursorID)
+			return err
+		}
+		res, err = client.Do(c, "FT.CURSOR", "READ", sel.Index, cursorID, "COUNT", size).Result()
+	}
+	return err
+}
+
+// aggregateKeys returns the @__key of each row of an FT.AGGREGATE batch, as a RESP3 map or a RESP2
+// [count, attributes, ...] list.
+func aggregateKeys(res interface{}) []string {
+	var keys []string
+	add := func(attrs interface{}) {
+		if m, ok := replyMap(attrs); ok {
+			if key := replyString(m["__key"]); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	}
+	switch r := res.(type) {
+	case map[interface{}]interface{}:
+		results, _ := r["results"].([]interface{})
+		for _, item := range results {
+			if m, ok := replyMap(item); ok {
+				add(m["extra_attributes"])
+			}
+		}
+	case []interface{}:
+		for i := 1; i < len(r); i++ {
+			add(r[i])
+		}
+	}
+	return keys
+}
+
+// scanPattern returns the SCAN MATCH pattern for keys starting with prefix.
+func scanPattern(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('*')
+	return b.String()
+}
@@ -0,0 +1,344 @@
+package redisutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NDJSON export and import.
+//
+// ExportNDJSON streams whole documents as newline-delimited JSON, one {"key": ..., "doc": ...} object
+// per line. Documents are selected by key prefix, by a query over an index, or both (see EachKeyPage),
+// and fetched with one JSON.MGET per batch. After every batch the export reports an ExportCheckpoint;
+// passing it back as Resume continues after that batch. ImportNDJSON writes such a stream back in
+// pipelined batches, retried like BulkGenerate's, and reports how many lines are done so an interrupted
+// import can skip them.
+
+// NDJSONRecord is one line of an export.
+type NDJSONRecord struct {
+	Key string          `json:"key"`
+	Doc json.RawMessage `json:"doc"`
+}
+
+// ExportCheckpoint is the position of an export after a batch. Cursor is the SCAN cursor of a prefix
+// export, or the number of matches already read by a query export. Exported counts the documents written.
+type ExportCheckpoint struct {
+	Cursor   uint64 `json:"cursor"`
+	Exported int64  `json:"exported"`
+	Done     bool   `json:"done"`
+}
+
+// NDJSONExportOptions selects the documents ExportNDJSON writes. Prefix, Index or both must be set; Query
+// (RediSearch syntax, default "*") needs Index. With an index and a prefix, only the matches whose key
+// starts with the prefix are written. BatchSize defaults to DefaultBulkBatchSize.
+//
+// A query export resumes by skipping the matches already read, so it assumes the index has not changed in
+// between. A prefix export follows SCAN's guarantees (see EachKeyPage).
+type NDJSONExportOptions struct {
+	Prefix    string
+	Index     string
+	Query     string
+	BatchSize int
+	Resume    ExportCheckpoint
+	// OnBatch, if set, is called after each batch has been written to the output, with the checkpoint to
+	// resume from. An error stops the export.
+	OnBatch func(ExportCheckpoint) error
+}
+
+// ExportNDJSON writes the documents selected by opts to w and returns the last checkpoint. When ctx is
+// cancelled the export stops after the current batch and returns ctx.Err().
+func ExportNDJSON(c context.Context, client *redis.Client, w io.Writer, opts NDJSONExportOptions) (ExportCheckpoint, error) {
+	if opts.BatchSize == 0 {
+		opts.BatchSize = DefaultBulkBatchSize
+	}
+	if opts.BatchSize < 1 || opts.BatchSize > MaxBulkBatchSize {
+		return opts.Resume, fmt.Errorf("batch size must be between 1 and %d", MaxBulkBatchSize)
+	}
+	sel := KeySelection{Prefix: opts.Prefix, Index: opts.Index, Query: opts.Query}
+	if err := sel.Validate(); err != nil {
+		return opts.Resume, err
+	}
+	e := &ndjsonExport{client: client, w: w, opts: opts, cp: opts.Resume}
+	if e.cp.Done {
+		return e.cp, nil
+	}
+	err := EachKeyPage(c, client, sel, opts.BatchSize, e.cp.Cursor, func(keys []string, position uint64, done bool) error {
+		return e.emit(c, keys, position, done)
+	})
+	return e.cp, err
+}
+
+type ndjsonExport struct {
+	client *redis.Client
+	w      io.Writer
+	opts   NDJSONExportOptions
+	cp     ExportCheckpoint
+	buf    []byte
+}
+
+// emit fetches and writes the documents of keys, then moves the checkpoint to position. Keys deleted
+// since they were listed are skipped.
+func (e *ndjsonExport) emit(c context.Context, keys []string, position uint64, done bool) error {
+	docs, err := fetchDocuments(c, e.client, keys)
+	if err != nil {
+		return err
+	}
+	e.buf = e.buf[:0]
+	n := int64(0)
+	for i, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		line, err := json.Marshal(NDJSONRecord{Key: keys[i], Doc: doc})
+		if err != nil {
+			return fmt.Errorf("%s: %w", keys[i], err)
+		}
+		e.buf = append(append(e.buf, line...), '\n')
+		n++
+	}
+	if len(e.buf) > 0 {
+		if _, err := e.w.Write(e.buf); err != nil {
+			return err
+		}
+	}
+	e.cp.Cursor, e.cp.Done = position, done
+	e.cp.Exported += n
+	if e.opts.OnBatch != nil {
+		return e.opts.OnBatch(e.cp)
+	}
+	return nil
+}
+
+// fetchDocuments reads the documents at keys with JSON.MGET. Missing keys give nil documents.
+func fetchDocuments(c context.Context, client *redis.Client, keys []string) ([]json.RawMessage, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	args := make([]interface{}, 0, len(keys)+2)
+	args = append(args, "JSON.MGET")
+	for _, key := range keys {
+		args = append(args, key)
+	}
+	args = append(args, "$")
+	res, err := client.Do(c, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]json.RawMessage, len(keys))
+	for i, v := range res {
+		if i >= len(docs) || v == nil {
+			continue
+		}
+		var matches []json.RawMessage
+		if err := json.Unmarshal([]byte(replyString(v)), &matches); err == nil && len(matches) > 0 {
+			docs[i] = matches[0]
+		}
+	}
+	return docs, nil
+}
+
+// NDJSONImportOptions configures ImportNDJSON. Skip is the number of input lines to pass over first, such
+// as the lines done by an earlier, interrupted import.
+type NDJSONImportOptions struct {
+	BulkOptions
+	Skip int64
+	// OnCheckpoint, if set, is called with the number of input lines (skipped ones included) that are
+	// done: every document up to that line has been written or counted as failed. It is called from the
+	// worker goroutines, one call at a time, with increasing values.
+	OnCheckpoint func(lines int64)
+}
+
+// importBatch is a batch of parsed lines. end is the number of the batch's last line.
+type importBatch struct {
+	seq    int
+	end    int64
+	docs   []bulkDoc
+	failed int
+	err    error
+}
+
+// ImportNDJSON writes the documents of an NDJSON stream (plain or gzip-compressed) in pipelined batches
+// and returns the stats and the number of lines done. Blank lines and checkpoint lines are skipped; lines
+// that are not a {"key", "doc"} object count as failed. With more than one worker, batches may be
+// applied out of order. When ctx is cancelled no new batches are started and ctx.Err() is returned.
+func ImportNDJSON(c context.Context, client *redis.Client, r io.Reader, opts NDJSONImportOptions) (BulkStats, int64, error) {
+	if err := opts.Validate(); err != nil {
+		return BulkStats{}, opts.Skip, err
+	}
+	if opts.Skip < 0 {
+		return BulkStats{}, 0, fmt.Errorf("skip must not be negative")
+	}
+	br, err := decompressReader(r)
+	if err != nil {
+		return BulkStats{}, opts.Skip, err
+	}
+	run := newBulkRun(opts.BulkOptions)
+	// Batches finish out of order; lines only advances past a batch once every earlier one is done.
+	var (
+		cpMu    sync.Mutex
+		doneEnd = map[int]int64{}
+		nextSeq int
+		lines   = opts.Skip
+	)
+	markDone := func(seq int, end int64) {
+		cpMu.Lock()
+		defer cpMu.Unlock()
+		doneEnd[seq] = end
+		advanced := false
+		for {
+			end, ok := doneEnd[nextSeq]
+			if !ok {
+				break
+			}
+			delete(doneEnd, nextSeq)
+			lines, nextSeq, advanced = end, nextSeq+1, true
+		}
+		if advanced && opts.OnCheckpoint != nil {
+			opts.OnCheckpoint(lines)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	batchCh := make(chan *importBatch)
+	var readErr error
+	go func() {
+		defer close(batchCh)
+		readErr = readNDJSONBatches(ctx, br, opts.Skip, opts.BatchSize, &run.bytes, batchCh)
+		if readErr != nil {
+			cancel()
+		}
+	}()
+
+	runWorkers(opts.Workers, func() {
+		for b := range batchCh {
+			n, r, err := writeBatch(ctx, client, b.docs, opts.BulkOptions)
+			if ctx.Err() != nil {
+				// Only part of the batch may have been written: leave it to the next run.
+				run.written.Add(int64(n))
+				continue
+			}
+			if err == nil {
+				err = b.err
+			}
+			first := ""
+			if len(b.docs) > 0 {
+				first = b.docs[0].key
+			}
+			run.record(run.nextBatch(), n, b.failed+len(b.docs)-n, r, first, err)
+			markDone(b.seq, b.end)
+		}
+	})
+	stats := run.stats()
+	if readErr != nil {
+		return stats, lines, readErr
+	}
+	return stats, lines, c.Err()
+}
+
+// readNDJSONBatches skips the first skip lines of r and sends the rest in batches of size documents.
+// size receives the size of the documents read.
+func readNDJSONBatches(c context.Context, r *bufio.Reader, skip int64, size int, docBytes *atomic.Int64, out chan<- *importBatch) error {
+	line := int64(0)
+	for ; line < skip; line++ {
+		if err := skipLine(r); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	seq := 0
+	batch := &importBatch{docs: make([]bulkDoc, 0, size)}
+	pending := false
+	send := func() {
+		batch.seq, batch.end = seq, line
+		select {
+		case out <- batch:
+		case <-c.Done():
+			return
+		}
+		seq++
+		batch = &importBatch{docs: make([]bulkDoc, 0, size)}
+		pending = false
+	}
+	for {
+		data, err := r.ReadBytes('\n')
+		if len(data) > 0 {
+			line++
+			pending = true
+			key, doc, perr := parseNDJSONLine(data)
+			switch {
+			case perr != nil:
+				batch.failed++
+				batch.err = fmt.Errorf("line %d: %w", line, perr)
+			case key != "":
+				batch.docs = append(batch.docs, bulkDoc{key: key, data: string(doc)})
+				docBytes.Add(int64(len(doc)))
+			}
+			if len(batch.docs)+batch.failed == size {
+				send()
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line+1, err)
+		}
+		if c.Err() != nil {
+			return nil
+		}
+	}
+	if pending {
+		send()
+	}
+	return nil
+}
+
+// skipLine reads up to and including the next newline.
+func skipLine(r *bufio.Reader) error {
+	for {
+		data, err := r.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err == io.EOF && len(data) > 0 {
+			return nil
+		}
+		return err
+	}
+}
+
+// parseNDJSONLine returns the key and document of a line, or an empty key for a line to skip.
+func parseNDJSONLine(data []byte) (string, json.RawMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return "", nil, nil
+	}
+	var rec struct {
+		NDJSONRecord
+		Checkpoint json.RawMessage `json:"checkpoint"`
+	}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return "", nil, err
+	}
+	if rec.Key == "" {
+		if rec.Checkpoint != nil {
+			return "", nil, nil
+		}
+		return "", nil, errors.New("missing key")
+	}
+	if len(rec.Doc) == 0 || string(rec.Doc) == "null" {
+		return "", nil, fmt.Errorf("%s: missing doc", rec.Key)
+	}
+	return rec.Key, rec.Doc, nil
+}
@@ -0,0 +1,161 @@
+package redisutil
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Field projection lets callers fetch only some subtrees of a document instead of the whole "$".
+// Fields are JSONPaths; a projected document is an object keyed by each path without its leading
+// "$." (so "$.primaryIdentifiers.email" comes back as "primaryIdentifiers.email").
+
+// MaxProjectedFields bounds how many fields a single request may project.
+const MaxProjectedFields = 32
+
+// ParseFieldPaths turns a comma-separated fields list into JSONPaths. Plain dotted names such as
+// "customerId" or "primaryIdentifiers.email" are prefixed with "$.".
+func ParseFieldPaths(list string) ([]string, error) {
+	var paths []string
+	for _, f := range strings.Split(list, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if strings.ContainsAny(f, " \t\r\n") {
+			return nil, fmt.Errorf("invalid field %q", f)
+		}
+		if !strings.HasPrefix(f, "$") {
+			f = "$." + f
+		}
+		paths = append(paths, f)
+	}
+	if len(paths) > MaxProjectedFields {
+		return nil, fmt.Errorf("too many fields (max %d)", MaxProjectedFields)
+	}
+	return paths, nil
+}
+
+func projectionKey(path string) string {
+	key := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if key == "" {
+		return "$"
+	}
+	return key
+}
+
+// projectDocument builds a document from the per-path values RediSearch returns for RETURN/LOAD.
+// Projected searches run with DIALECT 3, where every value is a JSON array of the path's matches,
+// so strings and numbers keep their JSON types.
+func projectDocument(attrs map[string]interface{}, fields []string) json.RawMessage {
+	out := make(map[string]json.RawMessage, len(fields))
+	for _, path := range fields {
+		val, ok := attrs[path]
+		if !ok {
+			out[projectionKey(path)] = json.RawMessage("null")
+			continue
+		}
+		s := replyString(val)
+		var matches []json.RawMessage
+		if err := json.Unmarshal([]byte(s), &matches); err != nil {
+			// Not a DIALECT 3 array: keep valid JSON as is, anything else as a string
+			if json.Valid([]byte(s)) {
+				out[projectionKey(path)] = json.RawMessage(s)
+			} else {
+				quoted, _ := json.Marshal(s)
+				out[projectionKey(path)] = quoted
+			}
+			continue
+		}
+		out[projectionKey(path)] = firstOrAll(matches)
+	}
+	data, _ := json.Marshal(out)
+	return data
+}
+
+// firstOrAll unwraps a JSONPath match list: null for no match, the value for one, the list otherwise.
+func firstOrAll(matches []json.RawMessage) json.RawMessage {
+	switch len(matches) {
+	case 0:
+		return json.RawMessage("null")
+	case 1:
+		return matches[0]
+	default:
+		all, _ := json.Marshal(matches)
+		return all
+	}
+}
+
+// GetJSONFields runs JSON.GET key path... and returns an object with the first match of each path
+// (or all matches, as an array, for paths that match more than one value).
+func GetJSONFields(client *redis.Client, key string, paths []string) (json.RawMessage, error) {
+	args := []interface{}{"JSON.GET", key}
+	for _, p := range paths {
+		args = append(args, p)
+	}
+	raw, err := client.Do(ctx, args...).Text()
+	if err != nil {
+		return nil, err
+	}
+	matches, err := parsePathMatches(raw, paths)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage, len(paths))
+	for _, p := range paths {
+		out[projectionKey(p)] = firstOrAll(matches[p])
+	}
+	data, _ := json.Marshal(out)
+	return data, nil
+}
+
+// GetJSONPaths fetches the matches of paths in the documents at keys, with one pipelined JSON.GET per key.
+// Each result maps every path to its matches; it is nil when the key does not exist.
+func GetJSONPaths(c context.Context, client *redis.Client, keys []string, paths []string) ([]map[string][]json.RawMessage, error) {
+	pipe := client.Pipeline()
+	cmds := make([]*redis.Cmd, len(keys))
+	for i, key := range keys {
+		args := make([]interface{}, 0, 2+len(paths))
+		args = append(args, "JSON.GET", key)
+		for _, p := range paths {
+			args = append(args, p)
+		}
+		cmds[i] = pipe.Do(c, args...)
+	}
+	// Errors are read per command below
+	_, _ = pipe.Exec(c)
+	out := make([]map[string][]json.RawMessage, len(keys))
+	for i, cmd := range cmds {
+		raw, err := cmd.Text()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if out[i], err = parsePathMatches(raw, paths); err != nil {
+			return nil, fmt.Errorf("%s: %w", keys[i], err)
+		}
+	}
+	return out, nil
+}
+
+// parsePathMatches reads a JSON.GET reply for paths into the matches of each path.
+func parsePathMatches(raw string, paths []string) (map[string][]json.RawMessage, error) {
+	matches := map[string][]json.RawMessage{}
+	if len(paths) == 1 {
+		// A single path returns the bare match array instead of an object keyed by path
+		var arr []json.RawMessage
+		if err := json.Unmarshal([]byte(raw), &arr); err != nil {
+			return nil, err
+		}
+		matches[paths[0]] = arr
+	} else if err := json.Unmarshal([]byte(raw), &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
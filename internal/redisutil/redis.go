@@ -10,13 +10,14 @@
 //   by key identifiers such as email, phone, visitor_id, call_id, and others. Without these indexes, searching for
 //   specific customers or events would require scanning all documents, which is slow and inefficient. Index creation
 //   is required for the full-text and field-level search features provided by the API and CLI, allowing for rapid
-//   lookups and complex queries over large datasets.
+//   lookups and complex queries over large datasets. The index definitions come from the schema package.
+//   Each logical index name is an FT alias over a versioned physical index, so indexes can be rebuilt
+//   without downtime (see Reindex and RollbackIndex).
 //
 // Typical usage:
 //   client, err := redisutil.NewRedisClient(redisURL)
 //   err := redisutil.StoreJSON(client, "customer:123", customerObj)
-//   err := redisutil.CreateCustomerIndex(client)
-//   err := redisutil.CreateEventIndex(client)
+//   physical, err := redisutil.Reindex(ctx, client, schema.Get().MustIndex(schema.CustomerIndex), nil)
 //
 // This package expects Redis to have RedisJSON and RediSearch modules enabled.
 
@@ -67,93 +68,156 @@ func StoreJSON(client *redis.Client, key string, value interface{}) error {
 	return client.Do(ctx, "JSON.SET", key, "$", string(data)).Err()
 }
 
-func CreateCustomerIndex(client *redis.Client) error {
-	// Drop index if exists
-	client.Do(ctx, "FT.DROPINDEX", "customerIdx")
-	// Create index
-	return client.Do(ctx, "FT.CREATE", "customerIdx", "ON", "JSON", "PREFIX", "1", "customer:",
-		"SCHEMA",
-		"$.primaryIdentifiers.email", "AS", "email", "TEXT",
-		"$.primaryIdentifiers.phone", "AS", "phone", "TEXT",
-		"$.primaryIdentifiers.visitor_id", "AS", "visitor_id", "TEXT",
-	).Err()
-}
-
-func CreateEventIndex(client *redis.Client) error {
-	// Drop index if exists
-	client.Do(ctx, "FT.DROPINDEX", "eventIdx")
-	// Create index
-	return client.Do(ctx, "FT.CREATE", "eventIdx", "ON", "JSON", "PREFIX", "1", "event:",
-		"SCHEMA",
-		"$.identifiers.visitor_id", "AS", "visitor_id", "TEXT",
-		"$.identifiers.call_id", "AS", "call_id", "TEXT",
-		"$.identifiers.chat_id", "AS", "chat_id", "TEXT",
-		"$.identifiers.external_id", "AS", "external_id", "TEXT",
-		"$.identifiers.lead_id", "AS", "lead_id", "TEXT",
-		"$.identifiers.tickets_id", "AS", "tickets_id", "TEXT",
-	).Err()
-}
-
 func SearchFTS(client *redis.Client, index string, query string) ([]json.RawMessage, error) {
 	res, err := client.Do(ctx, "FT.SEARCH", index, query, "RETURN", "1", "$").Result()
 	if err != nil {
 		return nil, err
 	}
-	// Parse the new map-based response
-	resMap, ok := res.(map[interface{}]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type: %T", res)
+	page, err := parseSearchResults(res, true, nil)
+	return page.Docs, err
+}
+
+func SearchFTSWithLimit(client *redis.Client, index string, query string, limit int, offset int) ([]json.RawMessage, error) {
+	page, err := SearchFTSWithOptions(client, index, query, SearchOptions{Limit: limit, Offset: offset})
+	return page.Docs, err
+}
+
+// SearchOptions controls paging, sorting and projection of FT.SEARCH. SortBy must be a SORTABLE field
+// alias. Fields, if set, are the JSONPaths to return instead of the whole document (see ParseFieldPaths).
+type SearchOptions struct {
+	Limit    int
+	Offset   int
+	SortBy   string
+	SortDesc bool
+	Fields   []string
+}
+
+// SearchResult is one page of documents plus the total number of matches.
+// CursorID is the FT.CURSOR to read the next page from (0 when there is none).
+type SearchResult struct {
+	Total    int64
+	Docs     []json.RawMessage
+	CursorID int64
+}
+
+func SearchFTSWithOptions(client *redis.Client, index string, query string, opts SearchOptions) (SearchResult, error) {
+	args := append([]interface{}{"FT.SEARCH", index, query}, searchArgs(opts)...)
+	res, err := client.Do(ctx, args...).Result()
+	if err != nil {
+		return SearchResult{}, err
 	}
-	results, ok := resMap["results"].([]interface{})
-	if !ok {
-		return nil, nil
+	return parseSearchResults(res, true, opts.Fields)
+}
+
+// SearchKeys runs FT.SEARCH with NOCONTENT and returns the total number of matches and the keys of the
+// first limit of them.
+func SearchKeys(client *redis.Client, index string, query string, limit int) (int64, []string, error) {
+	res, err := client.Do(ctx, "FT.SEARCH", index, query, "NOCONTENT", "LIMIT", 0, limit).Result()
+	if err != nil {
+		return 0, nil, err
 	}
-	var out []json.RawMessage
-	for _, r := range results {
-		resultMap, ok := r.(map[interface{}]interface{})
-		if !ok {
-			continue
+	var keys []string
+	switch r := res.(type) {
+	case map[interface{}]interface{}:
+		results, _ := r["results"].([]interface{})
+		for _, item := range results {
+			if result, ok := replyMap(item); ok {
+				keys = append(keys, replyString(result["id"]))
+			}
 		}
-		extra, ok := resultMap["extra_attributes"].(map[interface{}]interface{})
-		if !ok {
-			continue
+		return replyInt(r["total_results"]), keys, nil
+	case []interface{}:
+		if len(r) == 0 {
+			return 0, nil, nil
 		}
-		raw, ok := extra["$"].(string)
-		if ok {
-			out = append(out, json.RawMessage(raw))
+		for _, key := range r[1:] {
+			keys = append(keys, replyString(key))
 		}
+		return replyInt(r[0]), keys, nil
 	}
-	return out, nil
+	return 0, nil, fmt.Errorf("unexpected response type: %T", res)
 }
 
-func SearchFTSWithLimit(client *redis.Client, index string, query string, limit int, offset int) ([]json.RawMessage, error) {
-	res, err := client.Do(ctx, "FT.SEARCH", index, query, "LIMIT", offset, limit, "RETURN", "1", "$").Result()
-	if err != nil {
-		return nil, err
+// searchArgs returns the FT.SEARCH arguments that follow the query string.
+func searchArgs(opts SearchOptions) []interface{} {
+	var args []interface{}
+	if opts.SortBy != "" {
+		args = append(args, "SORTBY", opts.SortBy, sortDir(opts.SortDesc))
+	}
+	args = append(args, "LIMIT", opts.Offset, opts.Limit)
+	args = append(args, returnArgs("RETURN", opts.Fields)...)
+	return append(args, dialectArgs(opts.Fields)...)
+}
+
+// dialectArgs selects DIALECT 3 for projected searches so RETURN/LOAD values come back as JSON arrays.
+func dialectArgs(fields []string) []interface{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	return []interface{}{"DIALECT", 3}
+}
+
+// returnArgs builds a RETURN/LOAD clause for the projected fields, or for the whole document.
+func returnArgs(keyword string, fields []string) []interface{} {
+	if len(fields) == 0 {
+		return []interface{}{keyword, "1", "$"}
+	}
+	args := []interface{}{keyword, len(fields)}
+	for _, f := range fields {
+		args = append(args, f)
 	}
-	// Parse the new map-based response
-	resMap, ok := res.(map[interface{}]interface{})
-	if !ok {
-		return nil, fmt.Errorf("unexpected response type: %T", res)
+	return args
+}
+
+func sortDir(desc bool) string {
+	if desc {
+		return "DESC"
 	}
-	results, ok := resMap["results"].([]interface{})
-	if !ok {
-		return nil, nil
+	return "ASC"
+}
+
+// parseSearchResults reads the total and each result document from an FT.SEARCH or FT.AGGREGATE
+// reply: the "$" attribute, or the projected fields when fields is set. RESP3 replies are maps;
+// RESP2 replies are [total, (key,) attributes, ...] lists, where FT.SEARCH includes the document key
+// before each attribute list and FT.AGGREGATE does not.
+func parseSearchResults(res interface{}, withKeys bool, fields []string) (SearchResult, error) {
+	var page SearchResult
+	addDoc := func(attrs map[string]interface{}) {
+		if len(fields) > 0 {
+			page.Docs = append(page.Docs, projectDocument(attrs, fields))
+		} else if raw, ok := attrs["$"]; ok {
+			page.Docs = append(page.Docs, json.RawMessage(replyString(raw)))
+		}
 	}
-	var out []json.RawMessage
-	for _, r := range results {
-		resultMap, ok := r.(map[interface{}]interface{})
-		if !ok {
-			continue
+	switch r := res.(type) {
+	case map[interface{}]interface{}:
+		page.Total = replyInt(r["total_results"])
+		results, _ := r["results"].([]interface{})
+		for _, item := range results {
+			resultMap, ok := item.(map[interface{}]interface{})
+			if !ok {
+				continue
+			}
+			if extra, ok := replyMap(resultMap["extra_attributes"]); ok {
+				addDoc(extra)
+			}
+		}
+	case []interface{}:
+		if len(r) == 0 {
+			return page, nil
 		}
-		extra, ok := resultMap["extra_attributes"].(map[interface{}]interface{})
-		if !ok {
-			continue
+		page.Total = replyInt(r[0])
+		step := 1
+		if withKeys {
+			step = 2
 		}
-		raw, ok := extra["$"].(string)
-		if ok {
-			out = append(out, json.RawMessage(raw))
+		for i := step; i < len(r); i += step {
+			if attrs, ok := replyMap(r[i]); ok {
+				addDoc(attrs)
+			}
 		}
+	default:
+		return page, fmt.Errorf("unexpected response type: %T", res)
 	}
-	return out, nil
+	return page, nil
 }
@@ -0,0 +1,211 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+// Zero-downtime reindexing.
+//
+// Searches always go through the logical index name from the schema (e.g. customerIdx), which is an
+// FT alias pointing at a versioned physical index (customerIdx_v3). Reindexing builds the next version
+// next to the live one, waits until FT.INFO reports it fully indexed and only then moves the alias with
+// FT.ALIASUPDATE. The version that was live before the swap is kept so RollbackIndex can point the
+// alias back at it; older versions are dropped (without DD, so documents are never deleted).
+
+// ReindexPollInterval is how often FT.INFO is polled while a new index version is being built.
+var ReindexPollInterval = 500 * time.Millisecond
+
+// VersionedIndexName returns the physical index name for a given version of a logical index.
+func VersionedIndexName(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// ListIndexes returns the names of all physical indexes (FT._LIST).
+func ListIndexes(client *redis.Client) ([]string, error) {
+	res, err := client.Do(ctx, "FT._LIST").Result()
+	if err != nil {
+		return nil, err
+	}
+	return replyStrings(res), nil
+}
+
+// IndexVersions returns the existing versions of a logical index, sorted ascending.
+func IndexVersions(client *redis.Client, alias string) ([]int, error) {
+	names, err := ListIndexes(client)
+	if err != nil {
+		return nil, err
+	}
+	re := regexp.MustCompile("^" + regexp.QuoteMeta(alias) + `_v(\d+)$`)
+	var versions []int
+	for _, name := range names {
+		if m := re.FindStringSubmatch(name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			versions = append(versions, n)
+		}
+	}
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// CurrentIndex resolves the physical index currently served under the logical name.
+// It returns "" when nothing answers to that name.
+func CurrentIndex(client *redis.Client, alias string) (string, error) {
+	info, err := GetIndexInfo(client, alias)
+	if err != nil {
+		if IsUnknownIndex(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return info.IndexName, nil
+}
+
+// IndexingProgress reports FT.INFO percent_indexed (0..1) and whether a background scan is still running.
+func IndexingProgress(client *redis.Client, name string) (percent float64, indexing bool, err error) {
+	info, err := GetIndexInfo(client, name)
+	if err != nil {
+		return 0, false, err
+	}
+	return info.PercentIndexed, info.Indexing, nil
+}
+
+// WaitForIndexing polls FT.INFO until the index has finished its initial scan or ctx is done.
+// onProgress, if not nil, receives every percent_indexed sample.
+func WaitForIndexing(c context.Context, client *redis.Client, name string, onProgress func(percent float64)) error {
+	ticker := time.NewTicker(ReindexPollInterval)
+	defer ticker.Stop()
+	for {
+		percent, indexing, err := IndexingProgress(client, name)
+		if err != nil {
+			return err
+		}
+		if onProgress != nil {
+			onProgress(percent)
+		}
+		if !indexing && percent >= 1 {
+			return nil
+		}
+		select {
+		case <-c.Done():
+			return c.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// StartReindex creates the next physical version of an index. RediSearch starts indexing the existing
+// documents in the background as soon as FT.CREATE returns.
+func StartReindex(client *redis.Client, idx *schema.Index) (string, error) {
+	versions, err := IndexVersions(client, idx.Name)
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1] + 1
+	}
+	name := VersionedIndexName(idx.Name, next)
+	if err := client.Do(ctx, idx.CreateArgs(name)...).Err(); err != nil {
+		return "", fmt.Errorf("create %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// SwapAlias points the logical index name at physical, keeping the previously live version for rollback
+// and dropping every other version. A legacy physical index that still uses the logical name itself is
+// dropped (keeping its documents) so the name can become an alias.
+func SwapAlias(client *redis.Client, alias string, physical string) error {
+	previous, err := CurrentIndex(client, alias)
+	if err != nil {
+		return err
+	}
+	if previous == alias {
+		if err := client.Do(ctx, "FT.DROPINDEX", alias).Err(); err != nil {
+			return fmt.Errorf("drop legacy index %s: %w", alias, err)
+		}
+		previous = ""
+	}
+	if err := client.Do(ctx, "FT.ALIASUPDATE", alias, physical).Err(); err != nil {
+		return fmt.Errorf("point %s at %s: %w", alias, physical, err)
+	}
+	versions, err := IndexVersions(client, alias)
+	if err != nil {
+		return err
+	}
+	for _, v := range versions {
+		name := VersionedIndexName(alias, v)
+		if name == physical || name == previous {
+			continue
+		}
+		if err := client.Do(ctx, "FT.DROPINDEX", name).Err(); err != nil {
+			slog.Warn("failed to drop old index version", "index", name, "error", err)
+		}
+	}
+	return nil
+}
+
+// FinishReindex waits for a version created by StartReindex to be fully indexed and then swaps the alias.
+// Searches keep hitting the previous version until the swap.
+func FinishReindex(c context.Context, client *redis.Client, alias string, physical string, onProgress func(percent float64)) error {
+	if err := WaitForIndexing(c, client, physical, onProgress); err != nil {
+		return fmt.Errorf("waiting for %s: %w", physical, err)
+	}
+	return SwapAlias(client, alias, physical)
+}
+
+// Reindex builds a new version of idx and swaps the alias once it is fully indexed.
+func Reindex(c context.Context, client *redis.Client, idx *schema.Index, onProgress func(percent float64)) (string, error) {
+	physical, err := StartReindex(client, idx)
+	if err != nil {
+		return "", err
+	}
+	return physical, FinishReindex(c, client, idx.Name, physical, onProgress)
+}
+
+// RollbackIndex points the logical index name back at the newest version older than the live one.
+func RollbackIndex(client *redis.Client, alias string) (string, error) {
+	current, err := CurrentIndex(client, alias)
+	if err != nil {
+		return "", err
+	}
+	if current == "" {
+		return "", fmt.Errorf("%s does not point at any index", alias)
+	}
+	currentVersion := -1
+	if strings.HasPrefix(current, alias+"_v") {
+		currentVersion, _ = strconv.Atoi(strings.TrimPrefix(current, alias+"_v"))
+	}
+	versions, err := IndexVersions(client, alias)
+	if err != nil {
+		return "", err
+	}
+	target := ""
+	for _, v := range versions {
+		if v < currentVersion {
+			target = VersionedIndexName(alias, v)
+		}
+	}
+	if target == "" {
+		return "", fmt.Errorf("no previous version of %s to roll back to (live: %s)", alias, current)
+	}
+	if err := client.Do(ctx, "FT.ALIASUPDATE", alias, target).Err(); err != nil {
+		return "", fmt.Errorf("point %s at %s: %w", alias, target, err)
+	}
+	return target, nil
+}
+
+// IsUnknownIndex reports whether err is RediSearch's reply for an index that does not exist.
+func IsUnknownIndex(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "unknown index") || strings.Contains(msg, "no such index") || strings.Contains(msg, "not found")
+}
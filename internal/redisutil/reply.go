@@ -0,0 +1,83 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Helpers to normalize go-redis replies, which arrive as RESP3 maps or RESP2 flat key/value lists
+// depending on the negotiated protocol.
+
+// replyMap converts a RESP3 map or a RESP2 [k1, v1, k2, v2, ...] list into a string-keyed map.
+func replyMap(v interface{}) (map[string]interface{}, bool) {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			out[replyString(k)] = val
+		}
+		return out, true
+	case map[string]interface{}:
+		return t, true
+	case []interface{}:
+		if len(t)%2 != 0 {
+			return nil, false
+		}
+		out := make(map[string]interface{}, len(t)/2)
+		for i := 0; i < len(t); i += 2 {
+			out[replyString(t[i])] = t[i+1]
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func replyString(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case []byte:
+		return string(t)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+func replyFloat(v interface{}) float64 {
+	switch t := v.(type) {
+	case float64:
+		return t
+	case int64:
+		return float64(t)
+	default:
+		f, _ := strconv.ParseFloat(replyString(v), 64)
+		return f
+	}
+}
+
+func replyInt(v interface{}) int64 {
+	switch t := v.(type) {
+	case int64:
+		return t
+	case float64:
+		return int64(t)
+	default:
+		s := replyString(v)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return int64(f)
+	}
+}
+
+func replyStrings(v interface{}) []string {
+	list, _ := v.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		out = append(out, replyString(item))
+	}
+	return out
+}
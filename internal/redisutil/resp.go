@@ -0,0 +1,435 @@
+package redisutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RESP mass-insert files.
+//
+// ExportRESP writes generated documents as raw RESP commands (JSON.SET, or one JSON.MSET per batch) to
+// files instead of sending them, so large datasets can be built offline and loaded later with
+// `redis-cli --pipe` or ReplayRESP. Files can be gzip-compressed and sharded: batch b goes to part
+// b % Shards, and every part is a self-contained command stream that can be replayed on its own or in
+// parallel with the others.
+
+// ExportOptions configures ExportRESP. BatchSize, Workers and UseMSET have the same meaning as for
+// BulkGenerate; MaxRetries is unused. Gzip is implied by a ".gz" path.
+type ExportOptions struct {
+	BulkOptions
+	Path   string
+	Gzip   bool
+	Shards int
+}
+
+// ExportFile describes one written file. Bytes is the size of the RESP stream before compression.
+type ExportFile struct {
+	Path      string `json:"path"`
+	Documents int64  `json:"documents"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// ExportPaths returns the files an export to path writes: path itself, or path with -NNNNN-of-NNNNN
+// inserted before its extension (out.resp -> out-00001-of-00004.resp) when there are several shards.
+// ".gz" is appended when gzip is set and path does not already end with it.
+func ExportPaths(path string, gzip bool, shards int) []string {
+	if gzip && !strings.HasSuffix(path, ".gz") {
+		path += ".gz"
+	}
+	if shards <= 1 {
+		return []string{path}
+	}
+	base, ext := strings.TrimSuffix(path, ".gz"), ""
+	if strings.HasSuffix(path, ".gz") {
+		ext = ".gz"
+	}
+	ext = filepath.Ext(base) + ext
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+	paths := make([]string, shards)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%s-%05d-of-%05d%s", base, i+1, shards, ext)
+	}
+	return paths
+}
+
+// AppendRESP appends args to buf as a RESP array of bulk strings, the form redis-cli --pipe expects.
+func AppendRESP(buf []byte, args ...string) []byte {
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, a := range args {
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(a)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, a...)
+		buf = append(buf, '\r', '\n')
+	}
+	return buf
+}
+
+// exportShard is one output file. Writes are serialised by mu.
+type exportShard struct {
+	mu   sync.Mutex
+	file ExportFile
+	f    *os.File
+	buf  *bufio.Writer
+	gz   *gzip.Writer
+}
+
+func openExportShard(path string, compress bool) (*exportShard, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &exportShard{file: ExportFile{Path: path}, f: f}
+	if compress {
+		s.gz = gzip.NewWriter(f)
+		s.buf = bufio.NewWriterSize(s.gz, 1<<20)
+	} else {
+		s.buf = bufio.NewWriterSize(f, 1<<20)
+	}
+	return s, nil
+}
+
+func (s *exportShard) write(data []byte, docs int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.buf.Write(data); err != nil {
+		return err
+	}
+	s.file.Documents += int64(docs)
+	s.file.Bytes += int64(len(data))
+	return nil
+}
+
+func (s *exportShard) close() error {
+	err := s.buf.Flush()
+	if s.gz != nil {
+		if cerr := s.gz.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if cerr := s.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// ExportRESP writes documents 0..count-1, produced by gen, as RESP commands to the files of opts. gen is
+// called concurrently from the workers. The stats count documents written to the files; Bytes is the
+// uncompressed size of the commands. When ctx is cancelled no new batches are started, the files are
+// closed and ctx.Err() is returned.
+func ExportRESP(c context.Context, count int, opts ExportOptions, gen func(i int) (key string, doc interface{}, err error)) (BulkStats, []ExportFile, error) {
+	if err := opts.Validate(); err != nil {
+		return BulkStats{}, nil, err
+	}
+	if opts.Shards < 0 {
+		return BulkStats{}, nil, fmt.Errorf("shards must not be negative")
+	}
+	compress := opts.Gzip || strings.HasSuffix(opts.Path, ".gz")
+	paths := ExportPaths(opts.Path, compress, opts.Shards)
+	shards := make([]*exportShard, 0, len(paths))
+	closeAll := func() error {
+		var err error
+		for _, s := range shards {
+			if cerr := s.close(); err == nil {
+				err = cerr
+			}
+		}
+		return err
+	}
+	for _, p := range paths {
+		s, err := openExportShard(p, compress)
+		if err != nil {
+			closeAll()
+			return BulkStats{}, nil, err
+		}
+		shards = append(shards, s)
+	}
+
+	run := newBulkRun(opts.BulkOptions)
+	var (
+		mu       sync.Mutex
+		writeErr error
+	)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	starts := batchStarts(ctx, count, opts.BatchSize)
+	runWorkers(opts.Workers, func() {
+		var out []byte
+		mset := []string{"JSON.MSET"}
+		for s := range starts {
+			out, mset = out[:0], mset[:1]
+			end := min(s+opts.BatchSize, count)
+			docs, genFailed := 0, 0
+			var genErr error
+			first := ""
+			for i := s; i < end; i++ {
+				key, doc, err := gen(i)
+				if err == nil {
+					var data []byte
+					if data, err = json.Marshal(doc); err == nil {
+						if opts.UseMSET {
+							mset = append(mset, key, "$", string(data))
+						} else {
+							out = AppendRESP(out, "JSON.SET", key, "$", string(data))
+						}
+						if first == "" {
+							first = key
+						}
+						docs++
+						continue
+					}
+				}
+				genFailed++
+				genErr = err
+			}
+			if opts.UseMSET && docs > 0 {
+				out = AppendRESP(out, mset...)
+			}
+			batchNo := run.nextBatch()
+			if err := shards[(batchNo-1)%len(shards)].write(out, docs); err != nil {
+				mu.Lock()
+				if writeErr == nil {
+					writeErr = err
+				}
+				mu.Unlock()
+				cancel()
+				return
+			}
+			run.bytes.Add(int64(len(out)))
+			run.record(batchNo, docs, genFailed, 0, first, genErr)
+		}
+	})
+	closeErr := closeAll()
+	stats := run.stats()
+	files := make([]ExportFile, len(shards))
+	for i, s := range shards {
+		files[i] = s.file
+	}
+	switch {
+	case writeErr != nil:
+		return stats, files, writeErr
+	case closeErr != nil:
+		return stats, files, closeErr
+	}
+	return stats, files, c.Err()
+}
+
+// RESPReader reads the commands of a RESP mass-insert stream: arrays of bulk strings, as written by
+// AppendRESP and redis-cli.
+type RESPReader struct {
+	r *bufio.Reader
+}
+
+// NewRESPReader returns a reader for r, which may be gzip-compressed (detected from its first bytes).
+func NewRESPReader(r io.Reader) (*RESPReader, error) {
+	br, err := decompressReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &RESPReader{r: br}, nil
+}
+
+// decompressReader returns a buffered reader for r, decompressing it when it starts with the gzip magic
+// bytes.
+func decompressReader(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		br = bufio.NewReaderSize(gz, 1<<20)
+	}
+	return br, nil
+}
+
+// Limits of a command read by RESPReader: the server's default proto-max-bulk-len and a generous argument
+// count. Lengths are read from the file, so a corrupt header must fail rather than allocate.
+const (
+	maxRESPArgs    = 1 << 20
+	maxRESPBulkLen = 512 << 20
+)
+
+// Next returns the next command, or io.EOF at the end of the stream.
+func (r *RESPReader) Next() ([]string, error) {
+	n, err := r.header('*')
+	if err != nil {
+		return nil, err
+	}
+	if n > maxRESPArgs {
+		return nil, fmt.Errorf("resp: command of %d arguments exceeds the limit of %d", n, maxRESPArgs)
+	}
+	// Grown as arguments are read, so a header claiming more than the file holds allocates little.
+	args := make([]string, 0, min(n, 1024))
+	for range n {
+		size, err := r.header('$')
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if size > maxRESPBulkLen {
+			return nil, fmt.Errorf("resp: bulk string of %d bytes exceeds the limit of %d", size, maxRESPBulkLen)
+		}
+		var buf bytes.Buffer
+		buf.Grow(min(size+2, 1<<20))
+		if _, err := io.CopyN(&buf, r.r, int64(size+2)); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		data := buf.Bytes()
+		if data[size] != '\r' || data[size+1] != '\n' {
+			return nil, fmt.Errorf("resp: bulk string not terminated by CRLF")
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+// header reads a "<kind><n>\r\n" line.
+func (r *RESPReader) header(kind byte) (int, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line != "" {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if len(line) < 2 || line[0] != kind {
+		return 0, fmt.Errorf("resp: expected %q, got %q", kind, truncate(line, 20))
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("resp: invalid length %q", truncate(line, 20))
+	}
+	return n, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
+
+// ReplayRESP streams the commands of the RESP files at paths (plain or gzip-compressed) into client in
+// pipelined batches of opts.BatchSize commands, opts.Workers batches in flight at a time. Batches are
+// retried on transient errors like BulkGenerate's; commands rejected by the server count as failed. With
+// more than one worker, commands for the same key in different batches may be applied out of file order;
+// use one worker when that matters. Written counts commands that succeeded.
+func ReplayRESP(c context.Context, client *redis.Client, paths []string, opts BulkOptions) (BulkStats, error) {
+	if err := opts.Validate(); err != nil {
+		return BulkStats{}, err
+	}
+	run := newBulkRun(opts)
+	ctx, cancel := context.WithCancel(c)
+	defer cancel()
+	batchCh := make(chan [][]interface{})
+	var readErr error
+	go func() {
+		defer close(batchCh)
+		readErr = readRESPBatches(ctx, paths, opts.BatchSize, &run.bytes, batchCh)
+		if readErr != nil {
+			cancel()
+		}
+	}()
+
+	runWorkers(opts.Workers, func() {
+		for cmds := range batchCh {
+			n, r, err := retryBatch(ctx, cmds, *opts.MaxRetries, func(pending [][]interface{}) []error {
+				return pipelineErrors(ctx, client, pending)
+			})
+			run.record(run.nextBatch(), n, len(cmds)-n, r, commandKey(cmds[0]), err)
+		}
+	})
+	stats := run.stats()
+	if readErr != nil {
+		return stats, readErr
+	}
+	return stats, c.Err()
+}
+
+// readRESPBatches reads the files in order and sends their commands in batches of size. bytes receives
+// the size of the command arguments read.
+func readRESPBatches(c context.Context, paths []string, size int, bytes *atomic.Int64, out chan<- [][]interface{}) error {
+	for _, path := range paths {
+		if err := func() error {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r, err := NewRESPReader(f)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			batch := make([][]interface{}, 0, size)
+			for {
+				args, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return fmt.Errorf("%s: %w", path, err)
+				}
+				cmd := make([]interface{}, len(args))
+				for i, a := range args {
+					cmd[i] = a
+					bytes.Add(int64(len(a)))
+				}
+				if batch = append(batch, cmd); len(batch) == size {
+					select {
+					case out <- batch:
+					case <-c.Done():
+						return c.Err()
+					}
+					batch = make([][]interface{}, 0, size)
+				}
+			}
+			if len(batch) > 0 {
+				select {
+				case out <- batch:
+				case <-c.Done():
+					return c.Err()
+				}
+			}
+			return nil
+		}(); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// commandKey returns the first key of a command, for error reports.
+func commandKey(cmd []interface{}) string {
+	if len(cmd) < 2 {
+		return ""
+	}
+	return fmt.Sprint(cmd[1])
+}
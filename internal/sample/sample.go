@@ -0,0 +1,228 @@
+// Package sample draws random samples of documents, for example the identifiers a load test searches
+// for (see the sample_to_csv command).
+//
+// Keys are streamed from Redis page by page and offered to a Reservoir, which keeps a uniform random
+// sample of fixed size without holding every key in memory. The sampled documents are then fetched in
+// bounded batches, projecting only the output Columns, and written as CSV, NDJSON or Parquet by a Writer.
+// Columns are schema-aware: a field alias of the index, such as email, stands for its JSONPath.
+//
+// To make the sample look like production traffic rather than like the data set, Strata samples each
+// value of a field (such as event_type) separately, a WeightedReservoir favours recent documents with
+// RecencyLogWeight, and MissRow makes identifiers that match nothing.
+package sample
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/jricardooliveira/redis-document-data-search/internal/redisutil"
+	"github.com/jricardooliveira/redis-document-data-search/internal/schema"
+	"github.com/redis/go-redis/v9"
+)
+
+// Column is one output column: the first value matched by Path in each document, or the document key
+// when Path is empty.
+type Column struct {
+	Name string
+	Path string
+}
+
+// defaultColumns are the columns the k6 test in perf/ reads for the built-in indexes.
+var defaultColumns = map[string]string{
+	schema.CustomerIndex: "email,phone,visitor_id",
+	schema.EventIndex:    "visitor_id,call_id,chat_id,external_id,form2lead_id=lead_id,tickets_id",
+}
+
+// defaultTimeFields are the document times recency weighting uses for the built-in indexes.
+var defaultTimeFields = map[string]string{
+	schema.CustomerIndex: "$.updatedAt",
+	schema.EventIndex:    schema.EventTimeField,
+}
+
+// DefaultTimeField returns the field holding the time of the documents of idx, or "" when it has none.
+func DefaultTimeField(idx *schema.Index) string {
+	return defaultTimeFields[idx.Name]
+}
+
+// DefaultColumns returns the default column list for idx: the identifiers the k6 test uses for the
+// built-in indexes, every field alias for others.
+func DefaultColumns(idx *schema.Index) string {
+	if list, ok := defaultColumns[idx.Name]; ok {
+		return list
+	}
+	return strings.Join(idx.Aliases(), ",")
+}
+
+// ParseColumns parses a comma-separated column list. Each entry is [name=]field, where field is a field
+// alias of idx (standing for its JSONPath), a JSONPath such as $.personalData.name or a dotted path such as
+// personalData.name. The column name defaults to the alias or the path without "$.". The key column
+// always comes first.
+func ParseColumns(list string, idx *schema.Index) ([]Column, error) {
+	columns := []Column{{Name: "key"}}
+	seen := map[string]bool{"key": true}
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || entry == "key" {
+			continue
+		}
+		name, field, ok := strings.Cut(entry, "=")
+		if !ok {
+			name, field = "", name
+		}
+		name, field = strings.TrimSpace(name), strings.TrimSpace(field)
+		col, err := ParseField(field, idx)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			col.Name = name
+		}
+		if seen[col.Name] {
+			return nil, fmt.Errorf("duplicate column %q", col.Name)
+		}
+		seen[col.Name] = true
+		columns = append(columns, col)
+	}
+	if len(columns) == 1 {
+		return nil, fmt.Errorf("no columns")
+	}
+	if len(columns)-1 > redisutil.MaxProjectedFields {
+		return nil, fmt.Errorf("too many columns (max %d)", redisutil.MaxProjectedFields)
+	}
+	return columns, nil
+}
+
+// ParseField resolves one field of idx to a column: a field alias stands for its JSONPath and names the
+// column, a JSONPath such as $.personalData.name or a dotted path such as personalData.name is named after
+// the path without "$.".
+func ParseField(field string, idx *schema.Index) (Column, error) {
+	if f, ok := idx.Field(field); ok {
+		return Column{Name: f.Alias, Path: f.Path}, nil
+	}
+	if !strings.HasPrefix(field, "$") && !strings.Contains(field, ".") {
+		return Column{}, fmt.Errorf("unknown field %q (aliases: %s; use a JSONPath such as $.%s for other fields)",
+			field, strings.Join(idx.Aliases(), ", "), field)
+	}
+	paths, err := redisutil.ParseFieldPaths(field)
+	if err != nil {
+		return Column{}, err
+	}
+	if len(paths) != 1 {
+		return Column{}, fmt.Errorf("invalid field %q", field)
+	}
+	return Column{Name: strings.TrimPrefix(strings.TrimPrefix(paths[0], "$"), "."), Path: paths[0]}, nil
+}
+
+// Reservoir keeps a uniform random sample of at most size of the keys offered to it (Algorithm R): the
+// first size keys are kept, then the n-th key replaces a random kept one with probability size/n.
+type Reservoir struct {
+	size int
+	seen int64
+	keys []string
+	r    *rand.Rand
+}
+
+// NewReservoir returns an empty reservoir of size keys drawing from r.
+func NewReservoir(size int, r *rand.Rand) *Reservoir {
+	return &Reservoir{size: size, keys: make([]string, 0, min(size, 1<<20)), r: r}
+}
+
+// Offer considers key for the sample.
+func (s *Reservoir) Offer(key string) {
+	s.seen++
+	if len(s.keys) < s.size {
+		s.keys = append(s.keys, key)
+		return
+	}
+	if j := s.r.Int63n(s.seen); j < int64(s.size) {
+		s.keys[j] = key
+	}
+}
+
+// Seen returns how many keys were offered.
+func (s *Reservoir) Seen() int64 {
+	return s.seen
+}
+
+// Keys returns the sampled keys, sorted and without duplicates (SCAN may list a key twice).
+func (s *Reservoir) Keys() []string {
+	return sortedUnique(append([]string(nil), s.keys...))
+}
+
+// sortedUnique sorts keys in place and drops duplicates.
+func sortedUnique(keys []string) []string {
+	sort.Strings(keys)
+	out := keys[:0]
+	for i, k := range keys {
+		if i == 0 || k != keys[i-1] {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// Fetch reads the columns of the documents at keys, one row per document (as JSON values, the key
+// first). Keys that no longer exist are left out.
+func Fetch(c context.Context, client *redis.Client, keys []string, columns []Column) ([][]json.RawMessage, error) {
+	var paths []string
+	for _, col := range columns {
+		if col.Path != "" {
+			paths = append(paths, col.Path)
+		}
+	}
+	docs, err := redisutil.GetJSONPaths(c, client, keys, paths)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]json.RawMessage, 0, len(keys))
+	for i, matches := range docs {
+		if matches == nil {
+			continue
+		}
+		row := make([]json.RawMessage, len(columns))
+		for j, col := range columns {
+			if col.Path == "" {
+				row[j], _ = json.Marshal(keys[i])
+			} else if m := matches[col.Path]; len(m) > 0 {
+				row[j] = m[0]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Text renders a value for the text formats: strings without quotes, other values as JSON. It returns
+// false for a missing or null value.
+func Text(v json.RawMessage) (string, bool) {
+	if len(v) == 0 || string(v) == "null" {
+		return "", false
+	}
+	var s string
+	if v[0] == '"' && json.Unmarshal(v, &s) == nil {
+		return s, true
+	}
+	return string(v), true
+}
+
+// MissColumn is the column that tells the rows of real documents (false) from those made by MissRow (true).
+const MissColumn = "miss"
+
+// MissRow derives from row a row of identifiers that match no document, for load tests that should also
+// search for what is not there: every string value, the key included, gets a random "miss-<hex>-" prefix
+// (which keeps its shape, such as an email's domain); other values are left out.
+func MissRow(row []json.RawMessage, r *rand.Rand) []json.RawMessage {
+	prefix := fmt.Sprintf("miss-%08x-", r.Uint32())
+	miss := make([]json.RawMessage, len(row))
+	for i, v := range row {
+		var s string
+		if len(v) > 0 && v[0] == '"' && json.Unmarshal(v, &s) == nil && s != "" {
+			miss[i], _ = json.Marshal(prefix + s)
+		}
+	}
+	return miss
+}
@@ -0,0 +1,252 @@
+package sample
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxStrata bounds the distinct values of a stratification field; each stratum keeps its own reservoir.
+const MaxStrata = 1000
+
+// Allocation decides how a stratified sample is shared between the strata.
+type Allocation string
+
+const (
+	// AllocProportional gives each stratum its share of the documents, like a uniform sample but without
+	// the sampling noise in the per-stratum counts.
+	AllocProportional Allocation = "proportional"
+	// AllocEqual gives every stratum the same count, so rare values are as well represented as common
+	// ones. A stratum with fewer documents than its share gives the rest to the others.
+	AllocEqual Allocation = "equal"
+)
+
+// ParseAllocation validates an allocation name.
+func ParseAllocation(name string) (Allocation, error) {
+	switch a := Allocation(name); a {
+	case AllocProportional, AllocEqual:
+		return a, nil
+	}
+	return "", fmt.Errorf("unknown allocation %q (valid: proportional, equal)", name)
+}
+
+// WeightedReservoir keeps a weighted random sample without replacement of at most size of the keys offered
+// to it (Efraimidis-Spirakis A-ES): each key draws the priority ln(weight) - ln(E), E exponential, which
+// orders keys like A-ES's u^(1/weight), and the size highest priorities are kept. Working with ln(weight)
+// lets very small weights, such as those of old documents, keep their order instead of underflowing.
+type WeightedReservoir struct {
+	size int
+	seen int64
+	h    priorityHeap
+	r    *rand.Rand
+}
+
+// NewWeightedReservoir returns an empty weighted reservoir of size keys drawing from r. The heap grows as
+// keys are offered, as a stratum may hold far fewer keys than size.
+func NewWeightedReservoir(size int, r *rand.Rand) *WeightedReservoir {
+	return &WeightedReservoir{size: size, r: r}
+}
+
+// Offer considers key for the sample with the natural log of its weight; -Inf (weight 0) makes the key
+// a last resort, only sampled when there are not enough others.
+func (s *WeightedReservoir) Offer(key string, logWeight float64) {
+	s.seen++
+	p := prioritized{key: key, priority: logWeight - math.Log(s.r.ExpFloat64())}
+	if len(s.h) < s.size {
+		heap.Push(&s.h, p)
+		return
+	}
+	if s.size > 0 && p.priority > s.h[0].priority {
+		s.h[0] = p
+		heap.Fix(&s.h, 0)
+	}
+}
+
+// Seen returns how many keys were offered.
+func (s *WeightedReservoir) Seen() int64 {
+	return s.seen
+}
+
+// Keys returns the sampled keys with the n highest priorities (a weighted sample of n), sorted and without
+// duplicates.
+func (s *WeightedReservoir) Keys(n int) []string {
+	kept := append(priorityHeap(nil), s.h...)
+	sort.Slice(kept, func(i, j int) bool { return kept[i].priority > kept[j].priority })
+	keys := make([]string, 0, min(n, len(kept)))
+	for _, p := range kept[:min(n, len(kept))] {
+		keys = append(keys, p.key)
+	}
+	return sortedUnique(keys)
+}
+
+type prioritized struct {
+	key      string
+	priority float64
+}
+
+// priorityHeap is a min-heap on priority: the root is the first key to give way.
+type priorityHeap []prioritized
+
+func (h priorityHeap) Len() int            { return len(h) }
+func (h priorityHeap) Less(i, j int) bool  { return h[i].priority < h[j].priority }
+func (h priorityHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *priorityHeap) Push(x interface{}) { *h = append(*h, x.(prioritized)) }
+func (h *priorityHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
+
+// Strata samples keys grouped by the value of a field. Every stratum keeps a weighted reservoir of the full
+// sample size, as any of them may end up with all of it, so memory grows with min(documents, strata × size).
+type Strata struct {
+	size   int
+	r      *rand.Rand
+	strata map[string]*WeightedReservoir
+	seen   int64
+}
+
+// Stratum is the sample of one stratum: the keys sampled out of the Seen documents with field value Value
+// ("" when the field is missing).
+type Stratum struct {
+	Value string
+	Seen  int64
+	Keys  []string
+}
+
+// NewStrata returns empty strata for a sample of size keys drawing from r.
+func NewStrata(size int, r *rand.Rand) *Strata {
+	return &Strata{size: size, r: r, strata: map[string]*WeightedReservoir{}}
+}
+
+// Offer considers key, whose field has value, for the sample of its stratum with the natural log of its
+// weight (0 for a uniform sample). It fails once the field has more than MaxStrata distinct values.
+func (s *Strata) Offer(value, key string, logWeight float64) error {
+	res, ok := s.strata[value]
+	if !ok {
+		if len(s.strata) >= MaxStrata {
+			return fmt.Errorf("more than %d distinct values to stratify by", MaxStrata)
+		}
+		res = NewWeightedReservoir(s.size, s.r)
+		s.strata[value] = res
+	}
+	s.seen++
+	res.Offer(key, logWeight)
+	return nil
+}
+
+// Seen returns how many keys were offered.
+func (s *Strata) Seen() int64 {
+	return s.seen
+}
+
+// Sample shares the sample size between the strata by alloc and returns the strata sorted by value.
+func (s *Strata) Sample(alloc Allocation) []Stratum {
+	out := make([]Stratum, 0, len(s.strata))
+	for value, res := range s.strata {
+		out = append(out, Stratum{Value: value, Seen: res.Seen()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value < out[j].Value })
+	seen := make([]int64, len(out))
+	for i := range out {
+		seen[i] = out[i].Seen
+	}
+	weight := func(i int) float64 { return float64(seen[i]) }
+	if alloc == AllocEqual {
+		weight = func(int) float64 { return 1 }
+	}
+	for i, n := range allocate(s.size, seen, weight) {
+		out[i].Keys = s.strata[out[i].Value].Keys(n)
+	}
+	return out
+}
+
+// allocate shares size between strata in proportion to weight, none getting more than it has seen. Strata
+// whose share exceeds what they have are filled and the rest is shared again between the others; the final
+// shares are rounded by largest remainder so that they add up to size (or to everything seen).
+func allocate(size int, seen []int64, weight func(i int) float64) []int {
+	counts := make([]int, len(seen))
+	var open []int
+	for i, n := range seen {
+		if n > 0 {
+			open = append(open, i)
+		}
+	}
+	remaining := size
+	for len(open) > 0 && remaining > 0 {
+		total := 0.0
+		for _, i := range open {
+			total += weight(i)
+		}
+		var next []int
+		for _, i := range open {
+			if float64(remaining)*weight(i)/total >= float64(seen[i]) {
+				counts[i] = int(seen[i])
+			} else {
+				next = append(next, i)
+			}
+		}
+		if len(next) < len(open) {
+			for _, i := range open {
+				remaining -= counts[i]
+			}
+			open = next
+			continue
+		}
+		type share struct {
+			i    int
+			frac float64
+		}
+		shares := make([]share, len(open))
+		assigned := 0
+		for k, i := range open {
+			exact := float64(remaining) * weight(i) / total
+			counts[i] = int(exact)
+			assigned += counts[i]
+			shares[k] = share{i, exact - float64(counts[i])}
+		}
+		sort.SliceStable(shares, func(a, b int) bool { return shares[a].frac > shares[b].frac })
+		for k := 0; k < remaining-assigned && k < len(shares); k++ {
+			counts[shares[k].i]++
+		}
+		break
+	}
+	return counts
+}
+
+// RecencyLogWeight returns the natural log of the recency weight of a document with time v:
+// 2^(-age/halfLife), so a document halfLife older than another is half as likely to be sampled. v is Unix
+// seconds (or milliseconds), or an RFC 3339 string; times in the future count as now. A missing or
+// unreadable time gives -Inf.
+func RecencyLogWeight(v json.RawMessage, now time.Time, halfLife time.Duration) float64 {
+	t, ok := parseTime(v)
+	if !ok {
+		return math.Inf(-1)
+	}
+	age := max(now.Sub(t), 0)
+	return -math.Ln2 * age.Seconds() / halfLife.Seconds()
+}
+
+// parseTime reads a JSON time: a number of Unix seconds, or of milliseconds when too large to be seconds,
+// or an RFC 3339 string.
+func parseTime(v json.RawMessage) (time.Time, bool) {
+	s, ok := Text(v)
+	if !ok {
+		return time.Time{}, false
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		if math.Abs(f) >= 1e11 {
+			return time.UnixMilli(int64(f)), true
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), true
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	return t, err == nil
+}
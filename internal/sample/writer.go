@@ -0,0 +1,152 @@
+package sample
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Format is an output format for samples.
+type Format string
+
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat validates a format name. "" picks the format from the extension of path (.ndjson or
+// .jsonl, .parquet), defaulting to CSV.
+func ParseFormat(name, path string) (Format, error) {
+	if name == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ndjson", ".jsonl":
+			return FormatNDJSON, nil
+		case ".parquet":
+			return FormatParquet, nil
+		}
+		return FormatCSV, nil
+	}
+	switch f := Format(name); f {
+	case FormatCSV, FormatNDJSON, FormatParquet:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (valid: csv, ndjson, parquet)", name)
+}
+
+// Writer writes sampled rows, one JSON value per column (nil for a missing value).
+type Writer interface {
+	Write(row []json.RawMessage) error
+	// Close flushes the output; it does not close the underlying io.Writer.
+	Close() error
+}
+
+// NewWriter returns a writer of format to w for columns. CSV starts with a header line and renders values
+// with Text, leaving missing ones empty. NDJSON writes one object per row, keyed by column name, with
+// values keeping their JSON types. Parquet writes every column as an optional string (Text).
+func NewWriter(format Format, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		cw := &csvWriter{w: csv.NewWriter(w), record: make([]string, len(columns))}
+		for i, col := range columns {
+			cw.record[i] = col.Name
+		}
+		return cw, cw.w.Write(cw.record)
+	case FormatNDJSON:
+		nw := &ndjsonWriter{w: bufio.NewWriterSize(w, 1<<16), names: make([][]byte, len(columns))}
+		for i, col := range columns {
+			nw.names[i], _ = json.Marshal(col.Name)
+		}
+		return nw, nil
+	case FormatParquet:
+		group := parquet.Group{}
+		for _, col := range columns {
+			group[col.Name] = parquet.Optional(parquet.String())
+		}
+		schema := parquet.NewSchema("sample", group)
+		// The schema orders columns by name; index maps each of them back to its sample column.
+		pw := &parquetWriter{w: parquet.NewWriter(w, schema)}
+		for _, path := range schema.Columns() {
+			for i, col := range columns {
+				if col.Name == path[0] {
+					pw.index = append(pw.index, i)
+				}
+			}
+		}
+		return pw, nil
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvWriter struct {
+	w      *csv.Writer
+	record []string
+}
+
+func (cw *csvWriter) Write(row []json.RawMessage) error {
+	for i, v := range row {
+		cw.record[i], _ = Text(v)
+	}
+	return cw.w.Write(cw.record)
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	w     *bufio.Writer
+	names [][]byte
+	line  []byte
+}
+
+func (nw *ndjsonWriter) Write(row []json.RawMessage) error {
+	nw.line = append(nw.line[:0], '{')
+	for i, v := range row {
+		if i > 0 {
+			nw.line = append(nw.line, ',')
+		}
+		nw.line = append(append(nw.line, nw.names[i]...), ':')
+		if len(v) == 0 {
+			v = json.RawMessage("null")
+		}
+		nw.line = append(nw.line, v...)
+	}
+	nw.line = append(nw.line, '}', '\n')
+	_, err := nw.w.Write(nw.line)
+	return err
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nw.w.Flush()
+}
+
+type parquetWriter struct {
+	w     *parquet.Writer
+	index []int
+	row   parquet.Row
+}
+
+func (pw *parquetWriter) Write(row []json.RawMessage) error {
+	pw.row = pw.row[:0]
+	for leaf, i := range pw.index {
+		if s, ok := Text(row[i]); ok {
+			pw.row = append(pw.row, parquet.ValueOf(s).Level(0, 1, leaf))
+		} else {
+			pw.row = append(pw.row, parquet.NullValue().Level(0, 0, leaf))
+		}
+	}
+	_, err := pw.w.WriteRows([]parquet.Row{pw.row})
+	return err
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.w.Close()
+}
@@ -0,0 +1,352 @@
+// Package schema is the single source of truth for the RediSearch indexes used by the CLI and API.
+//
+// Each index is described by its name, the key prefix it covers and the list of JSONPaths it indexes
+// (with their alias and field type). The registry is used to generate the FT.CREATE arguments, to report
+// index information on /healthz and to validate which fields a search request is allowed to filter on.
+//
+// The built-in definitions live in Default(). They can be replaced by a YAML or JSON file pointed to by
+// the INDEX_SCHEMA_FILE environment variable, for example:
+//
+//	indexes:
+//	  - name: customerIdx
+//	    prefix: "customer:"
+//	    fields:
+//	      - {path: $.primaryIdentifiers.email, alias: email, type: TEXT}
+package schema
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Index names used by the customer and event search paths.
+const (
+	CustomerIndex = "customerIdx"
+	EventIndex    = "eventIdx"
+
+	// EventTimeField is the NUMERIC (Unix seconds) field used for event time-range filters.
+	EventTimeField = "timestamp"
+)
+
+// FieldType is a RediSearch field type. Identifiers should be TAG fields: they are matched exactly,
+// without tokenization or stemming. NUMERIC fields support range queries.
+type FieldType string
+
+const (
+	TypeText    FieldType = "TEXT"
+	TypeTag     FieldType = "TAG"
+	TypeNumeric FieldType = "NUMERIC"
+)
+
+// Field describes a single indexed JSONPath. Sortable fields can be used with SORTBY.
+type Field struct {
+	Path     string    `json:"path" yaml:"path"`
+	Alias    string    `json:"alias" yaml:"alias"`
+	Type     FieldType `json:"type" yaml:"type"`
+	Sortable bool      `json:"sortable,omitempty" yaml:"sortable"`
+}
+
+// Index describes a RediSearch index over JSON documents.
+type Index struct {
+	Name   string  `json:"name" yaml:"name"`
+	Prefix string  `json:"prefix" yaml:"prefix"`
+	Fields []Field `json:"fields" yaml:"fields"`
+}
+
+// Registry holds all known index definitions.
+type Registry struct {
+	Indexes []Index `json:"indexes" yaml:"indexes"`
+}
+
+// Default returns the built-in customer and event index definitions.
+func Default() *Registry {
+	return &Registry{
+		Indexes: []Index{
+			{
+				Name:   CustomerIndex,
+				Prefix: "customer:",
+				Fields: []Field{
+					{Path: "$.customerId", Alias: "customerId", Type: TypeTag},
+					{Path: "$.primaryIdentifiers.email", Alias: "email", Type: TypeTag},
+					{Path: "$.primaryIdentifiers.phone", Alias: "phone", Type: TypeTag},
+					{Path: "$.identifiers.visitor_ids[*]", Alias: "visitor_id", Type: TypeTag},
+					{Path: "$.confidenceScore", Alias: "confidenceScore", Type: TypeNumeric},
+					{Path: "$.merged", Alias: "merged", Type: TypeNumeric},
+					{Path: "$.deleted", Alias: "deleted", Type: TypeNumeric},
+				},
+			},
+			{
+				Name:   EventIndex,
+				Prefix: "event:",
+				Fields: []Field{
+					{Path: "$.event_id", Alias: "event_id", Type: TypeTag},
+					{Path: "$.event_type", Alias: "event_type", Type: TypeTag},
+					{Path: "$.identifiers.visitor_id", Alias: "visitor_id", Type: TypeTag},
+					{Path: "$.identifiers.call_id", Alias: "call_id", Type: TypeTag},
+					{Path: "$.identifiers.chat_id", Alias: "chat_id", Type: TypeTag},
+					{Path: "$.identifiers.external_id", Alias: "external_id", Type: TypeTag},
+					{Path: "$.identifiers.lead_id", Alias: "lead_id", Type: TypeTag},
+					{Path: "$.identifiers.tickets_id", Alias: "tickets_id", Type: TypeTag},
+					{Path: "$.timestamp_epoch", Alias: EventTimeField, Type: TypeNumeric, Sortable: true},
+				},
+			},
+		},
+	}
+}
+
+// Load reads a registry from a YAML or JSON file (JSON is valid YAML, so both go through the same decoder).
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file: %w", err)
+	}
+	var r Registry
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse schema file %s: %w", path, err)
+	}
+	if err := r.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid schema file %s: %w", path, err)
+	}
+	return &r, nil
+}
+
+var (
+	registry     *Registry
+	registryOnce sync.Once
+)
+
+// Get returns the process-wide registry: the file named by INDEX_SCHEMA_FILE if set, otherwise Default().
+func Get() *Registry {
+	registryOnce.Do(func() {
+		path := os.Getenv("INDEX_SCHEMA_FILE")
+		if path == "" {
+			registry = Default()
+			return
+		}
+		r, err := Load(path)
+		if err != nil {
+			panic("Failed to load index schema: " + err.Error())
+		}
+		registry = r
+	})
+	return registry
+}
+
+// Validate checks that every index has a name, a prefix and well-formed, uniquely aliased fields, and that
+// the customer and event indexes the search paths rely on are defined.
+func (r *Registry) Validate() error {
+	if len(r.Indexes) == 0 {
+		return fmt.Errorf("no indexes defined")
+	}
+	names := map[string]bool{}
+	for _, idx := range r.Indexes {
+		if idx.Name == "" {
+			return fmt.Errorf("index without a name")
+		}
+		if names[idx.Name] {
+			return fmt.Errorf("duplicate index %q", idx.Name)
+		}
+		names[idx.Name] = true
+		if idx.Prefix == "" {
+			return fmt.Errorf("index %s: missing prefix", idx.Name)
+		}
+		if len(idx.Fields) == 0 {
+			return fmt.Errorf("index %s: no fields", idx.Name)
+		}
+		aliases := map[string]bool{}
+		for _, f := range idx.Fields {
+			if !strings.HasPrefix(f.Path, "$") {
+				return fmt.Errorf("index %s: path %q must be a JSONPath starting with $", idx.Name, f.Path)
+			}
+			if f.Alias == "" {
+				return fmt.Errorf("index %s: path %s has no alias", idx.Name, f.Path)
+			}
+			if aliases[f.Alias] {
+				return fmt.Errorf("index %s: duplicate alias %q", idx.Name, f.Alias)
+			}
+			aliases[f.Alias] = true
+			switch f.Type {
+			case TypeText, TypeTag, TypeNumeric:
+			default:
+				return fmt.Errorf("index %s: field %s has unsupported type %q", idx.Name, f.Alias, f.Type)
+			}
+		}
+	}
+	for _, name := range []string{CustomerIndex, EventIndex} {
+		if !names[name] {
+			return fmt.Errorf("missing index %s", name)
+		}
+	}
+	return nil
+}
+
+// Index looks up an index definition by name.
+func (r *Registry) Index(name string) (*Index, bool) {
+	for i := range r.Indexes {
+		if r.Indexes[i].Name == name {
+			return &r.Indexes[i], true
+		}
+	}
+	return nil, false
+}
+
+// MustIndex is like Index but panics when the index is not defined.
+func (r *Registry) MustIndex(name string) *Index {
+	idx, ok := r.Index(name)
+	if !ok {
+		panic("index not defined in schema: " + name)
+	}
+	return idx
+}
+
+// Field looks up a field by its alias.
+func (idx *Index) Field(alias string) (Field, bool) {
+	for _, f := range idx.Fields {
+		if f.Alias == alias {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// Aliases returns the field aliases in declaration order.
+func (idx *Index) Aliases() []string {
+	out := make([]string, len(idx.Fields))
+	for i, f := range idx.Fields {
+		out[i] = f.Alias
+	}
+	return out
+}
+
+// ValidateFields returns an error naming the first (alphabetically) query field that is not part of the index.
+func (idx *Index) ValidateFields(identifiers map[string]string) error {
+	names := make([]string, 0, len(identifiers))
+	for k := range identifiers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := idx.Field(name); !ok {
+			return fmt.Errorf("unknown field %q for index %s (allowed: %s)", name, idx.Name, strings.Join(idx.Aliases(), ", "))
+		}
+	}
+	return nil
+}
+
+// CreateArgs returns the full FT.CREATE command for the index under the given physical name, ready for client.Do.
+func (idx *Index) CreateArgs(name string) []interface{} {
+	args := []interface{}{"FT.CREATE", name, "ON", "JSON", "PREFIX", "1", idx.Prefix, "SCHEMA"}
+	for _, f := range idx.Fields {
+		args = append(args, f.Path, "AS", f.Alias, string(f.Type))
+		if f.Sortable {
+			args = append(args, "SORTABLE")
+		}
+	}
+	return args
+}
+
+// ParseNumericRange turns a NUMERIC filter value into RediSearch range bounds. It accepts a single number
+// ("1"), a closed range ("0.8..1.0") or an open-ended one ("..1.0", "0.8..").
+func ParseNumericRange(val string) (min string, max string, err error) {
+	lo, hi, isRange := strings.Cut(val, "..")
+	if !isRange {
+		hi = lo
+	}
+	min, err = numericBound(lo, "-inf")
+	if err != nil {
+		return "", "", err
+	}
+	max, err = numericBound(hi, "+inf")
+	if err != nil {
+		return "", "", err
+	}
+	if !isRange && lo == "" {
+		return "", "", fmt.Errorf("empty numeric value")
+	}
+	return min, max, nil
+}
+
+func numericBound(s string, open string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return open, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("invalid number %q", s)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64), nil
+}
+
+// ParseTime parses a time filter value into Unix seconds. It accepts RFC3339 ("2024-05-01T00:00:00Z"),
+// Unix seconds ("1714521600") or a negative duration relative to now ("-24h").
+func ParseTime(val string, now time.Time) (int64, error) {
+	val = strings.TrimSpace(val)
+	if strings.HasPrefix(val, "-") {
+		if d, err := time.ParseDuration(val); err == nil {
+			return now.Add(d).Unix(), nil
+		}
+	}
+	if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return n, nil
+	}
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q: use RFC3339, Unix seconds or a relative duration like -24h", val)
+	}
+	return t.Unix(), nil
+}
+
+// TimeRangeBounds converts optional from/to filter values (see ParseTime) into NUMERIC range bounds.
+func TimeRangeBounds(from, to string, now time.Time) (min string, max string, err error) {
+	min, max = "-inf", "+inf"
+	if from != "" {
+		n, err := ParseTime(from, now)
+		if err != nil {
+			return "", "", fmt.Errorf("from: %w", err)
+		}
+		min = strconv.FormatInt(n, 10)
+	}
+	if to != "" {
+		n, err := ParseTime(to, now)
+		if err != nil {
+			return "", "", fmt.Errorf("to: %w", err)
+		}
+		max = strconv.FormatInt(n, 10)
+	}
+	return min, max, nil
+}
+
+// ValidateSort checks a sort_by/sort_dir pair and reports whether the order is descending.
+// An empty sortBy means no sorting.
+func (idx *Index) ValidateSort(sortBy, sortDir string) (desc bool, err error) {
+	switch strings.ToLower(sortDir) {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return false, fmt.Errorf("sort_dir must be asc or desc")
+	}
+	if sortBy == "" {
+		return desc, nil
+	}
+	f, ok := idx.Field(sortBy)
+	if !ok || !f.Sortable {
+		var sortable []string
+		for _, f := range idx.Fields {
+			if f.Sortable {
+				sortable = append(sortable, f.Alias)
+			}
+		}
+		return false, fmt.Errorf("cannot sort %s by %q (sortable: %s)", idx.Name, sortBy, strings.Join(sortable, ", "))
+	}
+	return desc, nil
+}
@@ -18,27 +18,26 @@ export const options = {
     ],
   };
 
-// Load CSVs into memory (relative to k6 run directory)
+// Load CSVs into memory (relative to k6 run directory). Columns are looked up by header name; the
+// optional miss column (sample_to_csv --miss-ratio) marks identifiers that should find nothing.
+function loadSample(path) {
+  const lines = open(path).split('\n').filter(Boolean);
+  const header = lines[0].split(',').map(s => s.replace(/^"|"$/g, ''));
+  return lines.slice(1).map(line => {
+    const values = line.split(',').map(s => s.replace(/^"|"$/g, ''));
+    const rec = {};
+    header.forEach((name, i) => { rec[name] = values[i]; });
+    rec.miss = rec.miss === 'true';
+    return rec;
+  });
+}
+
 const customerSamples = new SharedArray('customers', function () {
-  return open('./customer_sample.csv')
-    .split('\n')
-    .slice(1) // skip header
-    .filter(Boolean)
-    .map(line => {
-      const [key, email, phone, visitor_id] = line.split(',').map(s => s.replace(/^"|"$/g, ''));
-      return { key, email, phone, visitor_id };
-    });
+  return loadSample('./customer_sample.csv');
 });
 
 const eventSamples = new SharedArray('events', function () {
-  return open('./event_sample.csv')
-    .split('\n')
-    .slice(1)
-    .filter(Boolean)
-    .map(line => {
-      const [key, visitor_id, call_id, chat_id, external_id, form2lead_id, tickets_id] = line.split(',').map(s => s.replace(/^"|"$/g, ''));
-      return { key, visitor_id, call_id, chat_id, external_id, form2lead_id, tickets_id };
-    });
+  return loadSample('./event_sample.csv');
 });
 
 export function setup() {
@@ -64,6 +63,15 @@ export function setup() {
     }
   }
 
+// total is the number of matches a search response reports (-1 if it is not a search response).
+function total(res) {
+  try {
+    return JSON.parse(res.body).total;
+  } catch (e) {
+    return -1;
+  }
+}
+
 function pickRandom(arr) {
   return arr[Math.floor(Math.random() * arr.length)];
 }
@@ -77,16 +85,18 @@ export default function () {
         const field = pickRandom(nonEmptyFields);
         const value = rec[field];
         const url = `${BASE_URL}/search_customers?${field}=${encodeURIComponent(value)}`;
+        const outcome = rec.miss ? 'miss' : 'hit';
         let res = http.get(url, {
-          tags: { name: 'SearchCustomers' },
+          tags: { name: 'SearchCustomers', outcome },
         });
         if (res.status !== 200) {
           console.log(`[CUSTOMER FAIL] Status: ${res.status} URL: ${url} Body: ${res.body && res.body.slice(0, 200)}`);
         }
         check(res, {
           'customer search status is 200': (r) => r.status === 200,
-          'customer search has results': (r) => r.body && r.body.includes('results'),
-        });
+          'customer search finds a hit': (r) => rec.miss || total(r) > 0,
+          'customer search finds nothing for a miss': (r) => !rec.miss || total(r) === 0,
+        }, { outcome });
       }
     } else if (eventSamples.length > 0) {
       const rec = pickRandom(eventSamples);
@@ -96,16 +106,18 @@ export default function () {
         const field = pickRandom(nonEmptyFields);
         const value = rec[field];
         const url = `${BASE_URL}/search_events?${field}=${encodeURIComponent(value)}`;
+        const outcome = rec.miss ? 'miss' : 'hit';
         let res = http.get(url, {
-          tags: { name: 'SearchEvents' },
+          tags: { name: 'SearchEvents', outcome },
         });
         if (res.status !== 200) {
           console.log(`[EVENT FAIL] Status: ${res.status} URL: ${url} Body: ${res.body && res.body.slice(0, 200)}`);
         }
         check(res, {
           'event search status is 200': (r) => r.status === 200,
-          'event search has results': (r) => r.body && r.body.includes('results'),
-        });
+          'event search finds a hit': (r) => rec.miss || total(r) > 0,
+          'event search finds nothing for a miss': (r) => !rec.miss || total(r) === 0,
+        }, { outcome });
       }
     }
     //sleep(1);